@@ -0,0 +1,266 @@
+package app
+
+import (
+	"errors"
+	goflag "flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/cli/globalflag"
+	"github.com/shipengqi/component-base/options"
+	"github.com/shipengqi/component-base/term"
+	"github.com/shipengqi/component-base/version/verflag"
+)
+
+const (
+	// ExitOK is the exit code of a successful run.
+	ExitOK = 0
+	// ExitError is the exit code of a failed run.
+	ExitError = 1
+	// ExitUsage is the exit code of invalid flags, arguments or options.
+	ExitUsage = 2
+)
+
+// GlobalSection is the name of the flag section of the help and version flags.
+const GlobalSection = "global"
+
+// RunFunc runs the component with the arguments which are not flags,
+// after the options are completed and validated.
+type RunFunc func(args []string) error
+
+// Option configures an App.
+type Option func(*App)
+
+// WithOptions sets the options of the App, whose flags are added to the command and which are
+// completed and validated before the RunFunc is called.
+func WithOptions(opts options.Options) Option {
+	return func(a *App) {
+		a.options = opts
+	}
+}
+
+// WithRunFunc sets the function which runs the component.
+// The help is printed if the App has no RunFunc.
+func WithRunFunc(run RunFunc) Option {
+	return func(a *App) {
+		a.run = run
+	}
+}
+
+// WithSubcommands adds the Apps as the subcommands of the App.
+func WithSubcommands(commands ...*App) Option {
+	return func(a *App) {
+		a.commands = append(a.commands, commands...)
+	}
+}
+
+// WithShort sets the short description shown in the subcommand list of the parent command.
+func WithShort(short string) Option {
+	return func(a *App) {
+		a.short = short
+	}
+}
+
+// WithDescription sets the long description shown in the help.
+func WithDescription(description string) Option {
+	return func(a *App) {
+		a.description = description
+	}
+}
+
+// WithOutput writes all output of the App to w, including the help, the version and the errors,
+// instead of stdout and stderr. It is only effective for the root App.
+func WithOutput(w io.Writer) Option {
+	return func(a *App) {
+		a.out = w
+	}
+}
+
+// ExitCodeError is an error which makes the App exit with the given code.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// App is a command line application, e.g.
+//
+//	func main() {
+//		app.New("demo", app.WithOptions(NewOptions()), app.WithRunFunc(run)).Run()
+//	}
+type App struct {
+	name        string
+	short       string
+	description string
+	options     options.Options
+	run         RunFunc
+	commands    []*App
+	out         io.Writer
+
+	cmd *cobra.Command
+	fss cliflag.NamedFlagSets
+}
+
+// New creates an App with the given name, which is the name of its command.
+func New(name string, opts ...Option) *App {
+	a := &App{name: name}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Command returns the cobra command of the App, which is built on the first call.
+// The command of a subcommand is built by its parent.
+func (a *App) Command() *cobra.Command {
+	if a.cmd == nil {
+		a.buildCommand(true)
+	}
+	return a.cmd
+}
+
+// Run runs the App with the command line arguments and exits with its exit code.
+func (a *App) Run() {
+	os.Exit(a.Execute(os.Args[1:]))
+}
+
+// Execute runs the App with the given arguments and returns the exit code: ExitOK on success,
+// ExitUsage for invalid flags or options and ExitError for the other errors, unless the error
+// is an ExitCodeError. The errors are printed to the error output of the command,
+// all validation errors of the options at once.
+func (a *App) Execute(args []string) int {
+	root := a.Command()
+	cols, _, _ := term.TerminalSize(root.OutOrStdout())
+	a.setHelpFunc(cols)
+	root.SetArgs(args)
+
+	cmd, err := root.ExecuteC()
+	if err == nil {
+		return ExitOK
+	}
+
+	var invalid *invalidOptionsError
+	if errors.As(err, &invalid) {
+		options.PrintErrors(cmd.ErrOrStderr(), invalid.err)
+	} else {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+	}
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var usageErr *usageError
+	if invalid != nil || errors.As(err, &usageErr) {
+		return ExitUsage
+	}
+	return ExitError
+}
+
+// invalidOptionsError is the error of the options which can't be completed or validated.
+type invalidOptionsError struct {
+	err error
+}
+
+func (e *invalidOptionsError) Error() string {
+	return e.err.Error()
+}
+
+// usageError is the error of the flags or the arguments of a command.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+// buildCommand builds the cobra command of the App and its subcommands, the version flag
+// is added to the root command only.
+func (a *App) buildCommand(root bool) {
+	cmd := &cobra.Command{
+		Use:           a.name,
+		Short:         a.short,
+		Long:          a.description,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.Flags().SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
+	if root && a.out != nil {
+		cliflag.WithOutput(a.out)(cmd)
+	}
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+
+	if a.options != nil {
+		a.fss = a.options.Flags()
+	}
+	global := a.fss.FlagSet(GlobalSection)
+	globalflag.AddGlobalFlags(global, cmd.Name())
+	if root {
+		verflag.AddFlags(global)
+		// the flags of the go flag package, e.g. of the libraries, are shown in the global section
+		global.AddGoFlagSet(goflag.CommandLine)
+	}
+	for _, name := range a.fss.Order {
+		cmd.Flags().AddFlagSet(a.fss.FlagSets[name])
+	}
+	if root {
+		// marks the go flag package as parsed when the command line is parsed
+		cliflag.InitFlags(cmd.Flags())
+	}
+
+	for _, sub := range a.commands {
+		sub.buildCommand(false)
+		cmd.AddCommand(sub.cmd)
+	}
+	if a.run == nil {
+		// the arguments are unknown subcommands
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	} else {
+		// the arguments which are not subcommands are passed to the RunFunc
+		cmd.Args = cobra.ArbitraryArgs
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if root && verflag.PrintIfRequested(cmd.OutOrStdout()) {
+			return nil
+		}
+		if a.run == nil {
+			return cmd.Help()
+		}
+		if a.options != nil {
+			if err := options.CompleteAndValidate(a.options, a.fss); err != nil {
+				return &invalidOptionsError{err: err}
+			}
+			options.PrintWarnings(cmd.ErrOrStderr(), a.options)
+		}
+		return a.run(args)
+	}
+	a.cmd = cmd
+}
+
+// setHelpFunc sets the usage and help functions of the command and its subcommands,
+// which print the flags in sections wrapped at cols.
+func (a *App) setHelpFunc(cols int) {
+	cliflag.SetUsageAndHelpFunc(a.cmd, a.fss, cols)
+	for _, sub := range a.commands {
+		sub.setHelpFunc(cols)
+	}
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	goflag "flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/options"
+)
+
+type testOptions struct {
+	SecureServing *options.SecureServingOptions
+	Name          string
+}
+
+func newTestOptions() *testOptions {
+	return &testOptions{SecureServing: options.NewSecureServingOptions(), Name: "demo"}
+}
+
+func (o *testOptions) Flags() (fss cliflag.NamedFlagSets) {
+	o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
+	fss.FlagSet("generic").StringVar(&o.Name, "name", o.Name, "The name of the component.")
+	return fss
+}
+
+func (o *testOptions) Complete() error {
+	return nil
+}
+
+func (o *testOptions) Validate() []error {
+	errs := options.ValidateAll(o.SecureServing)
+	if len(o.Name) == 0 {
+		errs = append(errs, errors.New("--name: must not be empty"))
+	}
+	return errs
+}
+
+func execute(t *testing.T, a *App, args ...string) (int, string, string) {
+	t.Helper()
+	// the version flag is global
+	t.Cleanup(func() { _ = pflag.CommandLine.Set("version", "false") })
+	var out, errOut bytes.Buffer
+	a.Command().SetOut(&out)
+	a.Command().SetErr(&errOut)
+	code := a.Execute(args)
+	return code, out.String(), errOut.String()
+}
+
+func TestApp(t *testing.T) {
+	var (
+		ran    []string
+		subRan []string
+		runErr error
+		opts   *testOptions
+	)
+	newApp := func() *App {
+		ran, subRan, runErr = nil, nil, nil
+		opts = newTestOptions()
+		return New("demo",
+			WithDescription("The demo component."),
+			WithOptions(opts),
+			WithRunFunc(func(args []string) error {
+				ran = append([]string{}, args...)
+				return runErr
+			}),
+			WithSubcommands(New("sub", WithShort("A subcommand."), WithRunFunc(func(args []string) error {
+				subRan = append([]string{}, args...)
+				return nil
+			}))),
+		)
+	}
+
+	cases := []struct {
+		desc   string
+		args   []string
+		runErr error
+		code   int
+		ran    []string
+		subRan []string
+		out    []string
+		errOut []string
+	}{
+		{desc: "run", args: []string{"--name=test", "a", "b"}, code: ExitOK, ran: []string{"a", "b"}},
+		{desc: "subcommand", args: []string{"sub", "c"}, code: ExitOK, subRan: []string{"c"}},
+		{desc: "version", args: []string{"--version"}, code: ExitOK, out: []string{"Version:"}},
+		{
+			desc: "help",
+			args: []string{"--help"},
+			code: ExitOK,
+			out:  []string{"The demo component.", "Secure serving flags:", "Generic flags:", "Global flags:", "--version"},
+		},
+		{desc: "unknown flag", args: []string{"--unknown"}, code: ExitUsage, errOut: []string{"Error: unknown flag: --unknown"}},
+		{
+			desc: "invalid options",
+			args: []string{"--name=", "--tls-min-version=VersionTLS14"},
+			code: ExitUsage,
+			errOut: []string{
+				"Error: invalid options:\n",
+				"  [secure serving] --tls-min-version: unknown tls version \"VersionTLS14\"\n",
+				"  [generic] --name: must not be empty\n",
+			},
+		},
+		{desc: "run error", runErr: errors.New("failed"), code: ExitError, ran: []string{}, errOut: []string{"Error: failed\n"}},
+		{
+			desc:   "exit code error",
+			runErr: &ExitCodeError{Code: 3, Err: fmt.Errorf("not ready")},
+			code:   3,
+			ran:    []string{},
+			errOut: []string{"Error: not ready\n"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			a := newApp()
+			runErr = c.runErr
+			code, out, errOut := execute(t, a, c.args...)
+			if code != c.code {
+				t.Errorf("expect exit code %d but got %d, %s", c.code, code, errOut)
+			}
+			if !reflect.DeepEqual(ran, c.ran) || !reflect.DeepEqual(subRan, c.subRan) {
+				t.Errorf("expect runs %v and %v but got %v and %v", c.ran, c.subRan, ran, subRan)
+			}
+			for _, expect := range c.out {
+				if !strings.Contains(out, expect) {
+					t.Errorf("expect %q in the output\n%s", expect, out)
+				}
+			}
+			for _, expect := range c.errOut {
+				if !strings.Contains(errOut, expect) {
+					t.Errorf("expect %q in the error output\n%s", expect, errOut)
+				}
+			}
+		})
+	}
+}
+
+func TestAppWithoutRunFunc(t *testing.T) {
+	a := New("demo", WithSubcommands(New("sub", WithShort("A subcommand."))))
+	code, out, _ := execute(t, a)
+	if code != ExitOK || !strings.Contains(out, "Available Commands:") || !strings.Contains(out, "A subcommand.") {
+		t.Fatalf("expect the help, got %d\n%s", code, out)
+	}
+
+	code, _, errOut := execute(t, a, "unknown")
+	if code != ExitUsage || !strings.Contains(errOut, `unknown command "unknown" for "demo"`) {
+		t.Fatalf("expect an unknown command error, got %d, %s", code, errOut)
+	}
+}
+
+func TestAppWithOutput(t *testing.T) {
+	t.Cleanup(func() { _ = pflag.CommandLine.Set("version", "false") })
+	var out bytes.Buffer
+	a := New("demo", WithOutput(&out), WithSubcommands(New("sub", WithShort("A subcommand."))))
+	if code := a.Execute([]string{"--help"}); code != ExitOK || !strings.Contains(out.String(), "Available Commands:") {
+		t.Fatalf("expect the help, got %d\n%s", code, out.String())
+	}
+
+	out.Reset()
+	if code := a.Execute([]string{"sub", "--unknown"}); code != ExitUsage ||
+		!strings.Contains(out.String(), "Error: unknown flag: --unknown") {
+		t.Fatalf("expect the error, got %d\n%s", code, out.String())
+	}
+}
+
+type warnedOptions struct {
+	*testOptions
+	ClientTLS *options.ClientTLSOptions
+}
+
+func (o *warnedOptions) Flags() cliflag.NamedFlagSets {
+	fss := o.testOptions.Flags()
+	o.ClientTLS.AddFlags(fss.FlagSet("client tls"))
+	return fss
+}
+
+func (o *warnedOptions) Warnings() []string {
+	return options.WarningsAll(o.ClientTLS)
+}
+
+func TestAppWarnings(t *testing.T) {
+	ran := false
+	a := New("demo",
+		WithOptions(&warnedOptions{testOptions: newTestOptions(), ClientTLS: options.NewClientTLSOptions()}),
+		WithRunFunc(func([]string) error {
+			ran = true
+			return nil
+		}))
+	code, _, errOut := execute(t, a, "--client-insecure-skip-tls-verify")
+	if code != ExitOK || !ran {
+		t.Fatalf("expect a successful run, got %d\n%s", code, errOut)
+	}
+	if expect := "Warning: --client-insecure-skip-tls-verify is set"; !strings.HasPrefix(errOut, expect) {
+		t.Errorf("expect the warning %q but got %q", expect, errOut)
+	}
+}
+
+func TestAppGoFlags(t *testing.T) {
+	verbosity := goflag.Int("app-test-verbosity", 0, "The verbosity of the library.")
+	a := New("demo", WithRunFunc(func([]string) error { return nil }))
+	code, _, errOut := execute(t, a, "--app-test-verbosity=2")
+	if code != ExitOK || *verbosity != 2 {
+		t.Fatalf("expect the go flag to be set, got %d, %d\n%s", code, *verbosity, errOut)
+	}
+
+	a = New("demo", WithRunFunc(func([]string) error { return nil }))
+	code, out, _ := execute(t, a, "--help")
+	global := strings.Index(out, "Global flags:")
+	if code != ExitOK || global < 0 || !strings.Contains(out[global:], "--app-test-verbosity") {
+		t.Fatalf("expect the go flag in the global flags of the help, got %d\n%s", code, out)
+	}
+}
@@ -0,0 +1,3 @@
+// Package app builds the cobra command of a component from its options,
+// with the flag sections, the version flag and the help of cli/flag wired in.
+package app
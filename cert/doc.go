@@ -0,0 +1,2 @@
+// Package cert provides helpers for loading and serving x509 certificates.
+package cert
@@ -0,0 +1,312 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// DefaultReloadInterval is the default interval of checking the certificate and key files for changes.
+const DefaultReloadInterval = time.Minute
+
+// ReloadEvent describes the result of reloading a certificate/key pair.
+type ReloadEvent struct {
+	// CertFile is the path of the reloaded certificate file.
+	CertFile string
+	// KeyFile is the path of the reloaded key file.
+	KeyFile string
+	// Certificate is the certificate that is served after the reload.
+	// If the reload failed, it is the last good certificate.
+	Certificate *tls.Certificate
+	// Err is the error of the failed reload, it is nil on success.
+	Err error
+}
+
+// Listener is called after each reload of a certificate/key pair.
+type Listener func(event ReloadEvent)
+
+// certKeyContent holds the last good certificate of a certificate/key pair.
+type certKeyContent struct {
+	certFile, keyFile string
+
+	// modTime is the latest modification time of the certificate and key files.
+	modTime time.Time
+	// hash is the sha256 sum of the certificate and key contents.
+	hash []byte
+	cert atomic.Value // *tls.Certificate
+}
+
+// DynamicServingCertificates serves a default certificate and SNI certificates
+// loaded from certificate/key files, the files are polled and reloaded when they change.
+// If a reload fails, the last good certificate is served.
+type DynamicServingCertificates struct {
+	defaultCert *certKeyContent
+	sniCertKeys []cliflag.NamedCertKey
+	sniCerts    []*certKeyContent
+	// nameToCert maps the SNI names to the certificates, it is replaced when the names
+	// derived from a reloaded certificate change.
+	nameToCert atomic.Value // map[string]*certKeyContent
+
+	// reloadLock serializes the reloads.
+	reloadLock sync.Mutex
+
+	lock      sync.Mutex
+	listeners []Listener
+}
+
+// NewDynamicServingCertificates loads the given default certificate and SNI certificates.
+// defaultCertKey may be nil if there is no default certificate. The SNI names of a
+// certificate without explicit names are derived from the certificate each time it is
+// loaded, see ResolveSNINames for how conflicting names are resolved.
+func NewDynamicServingCertificates(defaultCertKey *cliflag.NamedCertKey, sniCertKeys []cliflag.NamedCertKey) (*DynamicServingCertificates, error) {
+	c := &DynamicServingCertificates{
+		sniCertKeys: sniCertKeys,
+	}
+	if defaultCertKey != nil {
+		content, err := newCertKeyContent(defaultCertKey.CertFile, defaultCertKey.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.defaultCert = content
+	}
+	for _, nck := range sniCertKeys {
+		content, err := newCertKeyContent(nck.CertFile, nck.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.sniCerts = append(c.sniCerts, content)
+	}
+	c.updateNames()
+	return c, nil
+}
+
+// updateNames maps the SNI names to the certificates, the names of the certificates
+// without explicit names are derived from their current leaf certificates.
+func (c *DynamicServingCertificates) updateNames() {
+	leafs := make([]*x509.Certificate, 0, len(c.sniCerts))
+	for _, content := range c.sniCerts {
+		leafs = append(leafs, content.current().Leaf)
+	}
+	// conflicts are reported by ResolveSNINames during validation,
+	// here the name simply goes to the pair which takes precedence.
+	nameToIndex, _ := resolveSNINames(c.sniCertKeys, leafs)
+	nameToCert := make(map[string]*certKeyContent, len(nameToIndex))
+	for name, i := range nameToIndex {
+		nameToCert[name] = c.sniCerts[i]
+	}
+	c.nameToCert.Store(nameToCert)
+}
+
+// AddListener adds a listener that is called after each reload.
+func (c *DynamicServingCertificates) AddListener(listener Listener) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.listeners = append(c.listeners, listener)
+}
+
+// Run polls the certificate and key files with the given interval until the context is done.
+// If interval is zero, DefaultReloadInterval is used.
+func (c *DynamicServingCertificates) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckNow()
+		}
+	}
+}
+
+// CheckNow checks all the certificate and key files once, and reloads the changed ones.
+// The SNI names of the reloaded certificates without explicit names are derived again
+// before the listeners are notified.
+func (c *DynamicServingCertificates) CheckNow() {
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+
+	var events []ReloadEvent
+	sniChanged := false
+	reload := func(content *certKeyContent, sni bool) {
+		changed, err := content.reload()
+		if !changed && err == nil {
+			return
+		}
+		sniChanged = sniChanged || (sni && changed)
+		events = append(events, ReloadEvent{
+			CertFile:    content.certFile,
+			KeyFile:     content.keyFile,
+			Certificate: content.current(),
+			Err:         err,
+		})
+	}
+	if c.defaultCert != nil {
+		reload(c.defaultCert, false)
+	}
+	for _, content := range c.sniCerts {
+		reload(content, true)
+	}
+	if sniChanged {
+		c.updateNames()
+	}
+	for _, event := range events {
+		c.notify(event)
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate, it returns the certificate matching the
+// requested server name, or the local IP address if no server name is sent. Exact names are
+// preferred over wildcard names. If nothing matches, the default certificate is returned.
+func (c *DynamicServingCertificates) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(hello.ServerName)
+	// clients don't send IP addresses as server names, use the address the client connected to instead.
+	if name == "" && hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			name = host
+		}
+	}
+	nameToCert, _ := c.nameToCert.Load().(map[string]*certKeyContent)
+	if content, ok := nameToCert[name]; ok {
+		return content.current(), nil
+	}
+
+	// replace the first label with a wildcard, e.g. "foo.example.com" -> "*.example.com"
+	labels := strings.Split(name, ".")
+	if len(labels) > 1 {
+		labels[0] = "*"
+		if content, ok := nameToCert[strings.Join(labels, ".")]; ok {
+			return content.current(), nil
+		}
+	}
+
+	if c.defaultCert != nil {
+		return c.defaultCert.current(), nil
+	}
+	return nil, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate, it returns the default
+// certificate, so a client certificate can be reloaded the same way as a serving certificate.
+func (c *DynamicServingCertificates) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if c.defaultCert != nil {
+		return c.defaultCert.current(), nil
+	}
+	// no certificate is sent to the server
+	return &tls.Certificate{}, nil
+}
+
+func (c *DynamicServingCertificates) notify(event ReloadEvent) {
+	c.lock.Lock()
+	listeners := make([]Listener, len(c.listeners))
+	copy(listeners, c.listeners)
+	c.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func newCertKeyContent(certFile, keyFile string) (*certKeyContent, error) {
+	content := &certKeyContent{certFile: certFile, keyFile: keyFile}
+	if _, err := content.reload(); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (c *certKeyContent) current() *tls.Certificate {
+	cert, _ := c.cert.Load().(*tls.Certificate)
+	return cert
+}
+
+// reload loads the certificate/key pair if the files have been changed since the last load.
+// It reports whether a new certificate is served. The last good certificate is kept on error.
+func (c *certKeyContent) reload() (bool, error) {
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	if modTime.Equal(c.modTime) && c.current() != nil {
+		return false, nil
+	}
+
+	certPEM, err := os.ReadFile(c.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyPEM, err := os.ReadFile(c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.New()
+	_, _ = sum.Write(certPEM)
+	_, _ = sum.Write(keyPEM)
+	hash := sum.Sum(nil)
+	if bytes.Equal(hash, c.hash) && c.current() != nil {
+		c.modTime = modTime
+		return false, nil
+	}
+
+	// remember the content even if it is invalid, so the same
+	// invalid content is only reported once.
+	c.modTime = modTime
+	c.hash = hash
+
+	cert, err := loadCertKeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("invalid certificate/key pair %s,%s: %v", c.certFile, c.keyFile, err)
+	}
+
+	c.cert.Store(cert)
+	return true, nil
+}
+
+// loadCertKeyPair parses the certificate/key pair and checks that the leaf certificate
+// is within its validity period.
+func loadCertKeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate is only valid from %s to %s", leaf.NotBefore, leaf.NotAfter)
+	}
+	cert.Leaf = leaf
+	return &cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
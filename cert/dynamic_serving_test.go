@@ -0,0 +1,197 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// writeTestCertKey writes a self-signed certificate and its key for the given
+// common name and SANs to dir, the modification time of the files is set to modTime.
+func writeTestCertKey(t *testing.T, dir, name, cn string, modTime time.Time, sans ...string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	writeTestFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeTestFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+	return certFile, keyFile
+}
+
+func writeTestFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	if cert == nil {
+		t.Fatal("expected a certificate, got nil")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestDynamicServingCertificatesReload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeTestCertKey(t, dir, "serving", "first", now.Add(-time.Minute))
+
+	certs, err := NewDynamicServingCertificates(&cliflag.NamedCertKey{CertFile: certFile, KeyFile: keyFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []ReloadEvent
+	certs.AddListener(func(event ReloadEvent) {
+		events = append(events, event)
+	})
+
+	// nothing changed
+	certs.CheckNow()
+	if len(events) != 0 {
+		t.Fatalf("expected no reload, got %v", events)
+	}
+
+	// same content with a new modification time
+	writeTestFile(t, certFile, mustReadFile(t, certFile), now)
+	certs.CheckNow()
+	if len(events) != 0 {
+		t.Fatalf("expected no reload for unchanged content, got %v", events)
+	}
+
+	// rotated certificate
+	writeTestCertKey(t, dir, "serving", "second", now.Add(time.Minute))
+	certs.CheckNow()
+	if len(events) != 1 || events[0].Err != nil {
+		t.Fatalf("expected one successful reload, got %v", events)
+	}
+	if cn := commonName(t, events[0].Certificate); cn != "second" {
+		t.Errorf("expected reloaded certificate %q, got %q", "second", cn)
+	}
+	cert, _ := certs.GetCertificate(&tls.ClientHelloInfo{})
+	if cn := commonName(t, cert); cn != "second" {
+		t.Errorf("expected served certificate %q, got %q", "second", cn)
+	}
+
+	// broken certificate, the last good one is kept
+	writeTestFile(t, certFile, []byte("invalid"), now.Add(2*time.Minute))
+	certs.CheckNow()
+	if len(events) != 2 || events[1].Err == nil {
+		t.Fatalf("expected a failed reload, got %v", events)
+	}
+	cert, _ = certs.GetCertificate(&tls.ClientHelloInfo{})
+	if cn := commonName(t, cert); cn != "second" {
+		t.Errorf("expected served certificate %q, got %q", "second", cn)
+	}
+
+	// the same broken content is reported only once
+	certs.CheckNow()
+	if len(events) != 2 {
+		t.Fatalf("expected no more reloads, got %v", events)
+	}
+}
+
+func TestDynamicServingCertificatesReloadSNINames(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	defaultCertFile, defaultKeyFile := writeTestCertKey(t, dir, "default", "default", now.Add(-time.Minute))
+	sniCertFile, sniKeyFile := writeTestCertKey(t, dir, "sni", "first", now.Add(-time.Minute), "a.example.com")
+
+	certs, err := NewDynamicServingCertificates(
+		&cliflag.NamedCertKey{CertFile: defaultCertFile, KeyFile: defaultKeyFile},
+		[]cliflag.NamedCertKey{{CertFile: sniCertFile, KeyFile: sniKeyFile}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := certs.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.example.com"})
+	if cn := commonName(t, cert); cn != "first" {
+		t.Errorf("expected served certificate %q, got %q", "first", cn)
+	}
+
+	// the rotated certificate is issued for another name
+	writeTestCertKey(t, dir, "sni", "second", now.Add(time.Minute), "b.example.com")
+	certs.CheckNow()
+
+	cert, _ = certs.GetCertificate(&tls.ClientHelloInfo{ServerName: "b.example.com"})
+	if cn := commonName(t, cert); cn != "second" {
+		t.Errorf("expected served certificate %q for the new name, got %q", "second", cn)
+	}
+	cert, _ = certs.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.example.com"})
+	if cn := commonName(t, cert); cn != "default" {
+		t.Errorf("expected served certificate %q for the old name, got %q", "default", cn)
+	}
+}
+
+func TestNewDynamicServingCertificatesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "serving.crt")
+	keyFile := filepath.Join(dir, "serving.key")
+	writeTestFile(t, certFile, []byte("invalid"), time.Now())
+	writeTestFile(t, keyFile, []byte("invalid"), time.Now())
+
+	_, err := NewDynamicServingCertificates(nil, []cliflag.NamedCertKey{{CertFile: certFile, KeyFile: keyFile}})
+	if err == nil {
+		t.Errorf("expected an error for an invalid certificate/key pair")
+	}
+}
+
+func mustReadFile(t *testing.T, file string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
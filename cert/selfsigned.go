@@ -0,0 +1,276 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// KeyType is the type of the private key of a generated certificate.
+type KeyType string
+
+const (
+	// KeyTypeRSA generates 2048-bit RSA keys.
+	KeyTypeRSA KeyType = "rsa"
+	// KeyTypeECDSA generates ECDSA keys on the P-256 curve.
+	KeyTypeECDSA KeyType = "ecdsa"
+	// KeyTypeEd25519 generates Ed25519 keys.
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+const (
+	// DefaultValidity is the default validity period of the generated certificates.
+	DefaultValidity = 365 * 24 * time.Hour
+	// DefaultPairName is the default file name prefix of the generated serving certificate and key.
+	DefaultPairName = "serving"
+
+	caPairName = "ca"
+	// renewFraction is the fraction of the configured validity which must be left, the
+	// certificates which expire earlier are regenerated instead of being reused.
+	renewFraction = 10
+)
+
+// SelfSignedConfig contains the configuration of a self-signed serving certificate.
+type SelfSignedConfig struct {
+	// Dir is the directory where the CA and serving certificates are written.
+	Dir string
+	// PairName is the file name prefix of the serving certificate and key,
+	// they are written to "<PairName>.crt" and "<PairName>.key". Defaults to DefaultPairName.
+	PairName string
+	// Hostnames are the DNS names the serving certificate is valid for.
+	Hostnames []string
+	// IPs are the IP addresses the serving certificate is valid for.
+	IPs []net.IP
+	// KeyType is the type of the generated keys. Defaults to KeyTypeECDSA.
+	KeyType KeyType
+	// Validity is the validity period of the generated certificates. Defaults to DefaultValidity.
+	Validity time.Duration
+}
+
+// GenerateSelfSignedCertKey creates a self-signed CA and a serving certificate signed by it
+// for the configured hostnames and IPs, and writes them to the configured directory as
+// "ca.crt", "ca.key", "<PairName>.crt" and "<PairName>.key".
+// Existing files are reused as long as they are valid for at least a tenth of the configured
+// validity, have the configured key type and the serving certificate covers all the hostnames and IPs.
+// A serving certificate signed by a reused CA expires no later than the CA.
+// It returns the NamedCertKey of the serving certificate.
+func GenerateSelfSignedCertKey(config SelfSignedConfig) (cliflag.NamedCertKey, error) {
+	if len(config.Dir) == 0 {
+		return cliflag.NamedCertKey{}, errors.New("no directory to write the self-signed certificates to")
+	}
+	if len(config.PairName) == 0 {
+		config.PairName = DefaultPairName
+	}
+	if len(config.KeyType) == 0 {
+		config.KeyType = KeyTypeECDSA
+	}
+	if config.Validity <= 0 {
+		config.Validity = DefaultValidity
+	}
+
+	caCertFile, caKeyFile := pairFiles(config.Dir, caPairName)
+	servingCertFile, servingKeyFile := pairFiles(config.Dir, config.PairName)
+	serving := cliflag.NamedCertKey{CertFile: servingCertFile, KeyFile: servingKeyFile}
+
+	ca, caKey, err := loadPair(caCertFile, caKeyFile)
+	if err != nil || !usable(ca, caKey, config) || !ca.IsCA {
+		ca, caKey, err = generateCA(config)
+		if err != nil {
+			return cliflag.NamedCertKey{}, err
+		}
+		if err = writePair(caCertFile, caKeyFile, ca, caKey); err != nil {
+			return cliflag.NamedCertKey{}, err
+		}
+	} else if servingCert, servingKey, err := loadPair(servingCertFile, servingKeyFile); err == nil &&
+		usable(servingCert, servingKey, config) && covers(servingCert, ca, config) {
+		return serving, nil
+	}
+
+	servingCert, servingKey, err := generateServingCert(config, ca, caKey)
+	if err != nil {
+		return cliflag.NamedCertKey{}, err
+	}
+	if err = writePair(servingCertFile, servingKeyFile, servingCert, servingKey); err != nil {
+		return cliflag.NamedCertKey{}, err
+	}
+	return serving, nil
+}
+
+func pairFiles(dir, pairName string) (string, string) {
+	return filepath.Join(dir, pairName+".crt"), filepath.Join(dir, pairName+".key")
+}
+
+// loadPair loads a certificate and its private key.
+func loadPair(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type %T", pair.PrivateKey)
+	}
+	return cert, key, nil
+}
+
+// usable reports whether the certificate is valid now, does not expire within a renewFraction
+// of the configured validity and has a key of the configured type.
+func usable(cert *x509.Certificate, key crypto.Signer, config SelfSignedConfig) bool {
+	now := time.Now()
+	if now.Before(cert.NotBefore) || cert.NotAfter.Sub(now) < config.Validity/renewFraction {
+		return false
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return config.KeyType == KeyTypeRSA
+	case *ecdsa.PrivateKey:
+		return config.KeyType == KeyTypeECDSA
+	case ed25519.PrivateKey:
+		return config.KeyType == KeyTypeEd25519
+	}
+	return false
+}
+
+// covers reports whether the serving certificate is signed by the CA and is valid
+// for all the configured hostnames and IPs.
+func covers(cert, ca *x509.Certificate, config SelfSignedConfig) bool {
+	if err := cert.CheckSignatureFrom(ca); err != nil {
+		return false
+	}
+	for _, hostname := range config.Hostnames {
+		if err := cert.VerifyHostname(hostname); err != nil {
+			return false
+		}
+	}
+	for _, ip := range config.IPs {
+		if err := cert.VerifyHostname(ip.String()); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func generateCA(config SelfSignedConfig) (*x509.Certificate, crypto.Signer, error) {
+	key, err := generateKey(config.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: fmt.Sprintf("%s-ca@%d", config.PairName, now.Unix()),
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(config.Validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := createCertificate(tmpl, tmpl, key.Public(), key)
+	return cert, key, err
+}
+
+func generateServingCert(config SelfSignedConfig, ca *x509.Certificate, caKey crypto.Signer) (*x509.Certificate, crypto.Signer, error) {
+	key, err := generateKey(config.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	commonName := config.PairName
+	if len(config.Hostnames) > 0 {
+		commonName = config.Hostnames[0]
+	}
+	now := time.Now()
+	// a reused CA may expire earlier, the serving certificate must not outlive it
+	notAfter := now.Add(config.Validity)
+	if ca.NotAfter.Before(notAfter) {
+		notAfter = ca.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		DNSNames:              config.Hostnames,
+		IPAddresses:           config.IPs,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	// RSA keys are also used for key encipherment
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	cert, err := createCertificate(tmpl, ca, key.Public(), caKey)
+	return cert, key, err
+}
+
+func createCertificate(tmpl, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unknown key type %q", keyType)
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// writePair writes the PEM encoded certificate and private key.
+func writePair(certFile, keyFile string, cert *x509.Certificate, key crypto.Signer) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(certFile), 0o700); err != nil {
+		return err
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o644); err != nil { // #nosec G306 -- certificates are public
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+}
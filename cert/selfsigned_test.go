@@ -0,0 +1,164 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSignedCertKey(t *testing.T) {
+	tests := []struct {
+		keyType KeyType
+		check   func(key interface{}) bool
+	}{
+		{keyType: KeyTypeRSA, check: func(key interface{}) bool { _, ok := key.(*rsa.PrivateKey); return ok }},
+		{keyType: KeyTypeECDSA, check: func(key interface{}) bool { _, ok := key.(*ecdsa.PrivateKey); return ok }},
+		{keyType: KeyTypeEd25519, check: func(key interface{}) bool { _, ok := key.(ed25519.PrivateKey); return ok }},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.keyType), func(t *testing.T) {
+			config := SelfSignedConfig{
+				Dir:       t.TempDir(),
+				Hostnames: []string{"localhost"},
+				IPs:       []net.IP{net.ParseIP("127.0.0.1")},
+				KeyType:   test.keyType,
+				Validity:  time.Hour,
+			}
+			nck, err := GenerateSelfSignedCertKey(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pair, err := tls.LoadX509KeyPair(nck.CertFile, nck.KeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(pair.PrivateKey) {
+				t.Errorf("expected a %s key, got %T", test.keyType, pair.PrivateKey)
+			}
+			leaf, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaf.NotAfter.After(time.Now().Add(time.Hour)) {
+				t.Errorf("expected the certificate to expire within an hour, got %s", leaf.NotAfter)
+			}
+
+			ca, _, err := loadPair(pairFiles(config.Dir, caPairName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+			for _, name := range []string{"localhost", "127.0.0.1"} {
+				if _, err = leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: name}); err != nil {
+					t.Errorf("expected the certificate to be valid for %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSelfSignedCertKeyReuse(t *testing.T) {
+	config := SelfSignedConfig{
+		Dir:       t.TempDir(),
+		Hostnames: []string{"localhost"},
+	}
+	nck, err := GenerateSelfSignedCertKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCertFile, _ := pairFiles(config.Dir, caPairName)
+	first := mustReadFile(t, nck.CertFile)
+	firstCA := mustReadFile(t, caCertFile)
+
+	// still valid and covers the names
+	if _, err = GenerateSelfSignedCertKey(config); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, mustReadFile(t, nck.CertFile)) {
+		t.Errorf("expected the serving certificate to be reused")
+	}
+
+	// a new name requires a new serving certificate signed by the same CA
+	config.Hostnames = append(config.Hostnames, "example.com")
+	if _, err = GenerateSelfSignedCertKey(config); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, mustReadFile(t, nck.CertFile)) {
+		t.Errorf("expected a new serving certificate")
+	}
+	if !bytes.Equal(firstCA, mustReadFile(t, caCertFile)) {
+		t.Errorf("expected the CA to be reused")
+	}
+
+	// a new key type requires a new CA
+	config.KeyType = KeyTypeRSA
+	if _, err = GenerateSelfSignedCertKey(config); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(firstCA, mustReadFile(t, caCertFile)) {
+		t.Errorf("expected a new CA")
+	}
+}
+
+func TestGenerateSelfSignedCertKeyRenew(t *testing.T) {
+	config := SelfSignedConfig{
+		Dir:       t.TempDir(),
+		Hostnames: []string{"localhost"},
+		Validity:  time.Hour,
+	}
+	nck, err := GenerateSelfSignedCertKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCertFile, _ := pairFiles(config.Dir, caPairName)
+	first := mustReadFile(t, nck.CertFile)
+	firstCA := mustReadFile(t, caCertFile)
+
+	// the certificates expire in an hour, which is less than a tenth of the new validity
+	config.Validity = 20 * time.Hour
+	if _, err = GenerateSelfSignedCertKey(config); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, mustReadFile(t, nck.CertFile)) || bytes.Equal(firstCA, mustReadFile(t, caCertFile)) {
+		t.Errorf("expected the certificates which are about to expire to be regenerated")
+	}
+}
+
+func TestGenerateSelfSignedCertKeyReusedCAExpiry(t *testing.T) {
+	config := SelfSignedConfig{
+		Dir:       t.TempDir(),
+		Hostnames: []string{"localhost"},
+		Validity:  time.Hour,
+	}
+	if _, err := GenerateSelfSignedCertKey(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// the CA which expires in an hour is still usable for the new validity,
+	// and a new name requires a new serving certificate signed by it
+	config.Validity = 5 * time.Hour
+	config.Hostnames = append(config.Hostnames, "example.com")
+	nck, err := GenerateSelfSignedCertKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := loadPair(nck.CertFile, nck.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _, err := loadPair(pairFiles(config.Dir, caPairName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.NotAfter.After(ca.NotAfter) {
+		t.Errorf("expected the serving certificate to expire no later than the CA at %s, got %s", ca.NotAfter, leaf.NotAfter)
+	}
+}
@@ -0,0 +1,127 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// sniClaim is an SNI name claimed by a certificate/key pair.
+type sniClaim struct {
+	// index is the position of the pair in the flag values.
+	index int
+	// explicit is true if the name was given in the flag value,
+	// false if it was derived from the certificate.
+	explicit bool
+}
+
+// before reports whether c takes precedence over other: explicit names beat
+// derived ones, and earlier flags beat later ones.
+func (c sniClaim) before(other sniClaim) bool {
+	if c.explicit != other.explicit {
+		return c.explicit
+	}
+	return c.index < other.index
+}
+
+func (c sniClaim) kind() string {
+	if c.explicit {
+		return "explicitly"
+	}
+	return "by certificate"
+}
+
+// CertificateNames returns the names a certificate is valid for: its DNS SANs
+// and IP SANs, or its common name if it has no SANs.
+func CertificateNames(cert *x509.Certificate) []string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 && len(cert.Subject.CommonName) > 0 && !strings.ContainsAny(cert.Subject.CommonName, " /:") {
+		names = append(names, strings.ToLower(cert.Subject.CommonName))
+	}
+	return names
+}
+
+// ResolveSNINames loads the certificates of the given NamedCertKeys and maps each SNI name
+// to the index of the NamedCertKey that serves it. The explicit names of a NamedCertKey are
+// used if there are any, otherwise the names are derived from its certificate.
+// If several NamedCertKeys claim the same name, explicit names beat derived ones and
+// earlier flags beat later ones, each conflict is reported as an error.
+func ResolveSNINames(namedCertKeys []cliflag.NamedCertKey) (map[string]int, []error) {
+	leafs := make([]*x509.Certificate, len(namedCertKeys))
+	var errs []error
+	for i, nck := range namedCertKeys {
+		if len(nck.Names) > 0 {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(nck.CertFile, nck.KeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to load %q: %v", nck.String(), err))
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to parse %q: %v", nck.String(), err))
+			continue
+		}
+		leafs[i] = leaf
+	}
+	nameToIndex, conflicts := resolveSNINames(namedCertKeys, leafs)
+	return nameToIndex, append(errs, conflicts...)
+}
+
+// resolveSNINames implements ResolveSNINames with the already loaded leaf certificates,
+// leafs[i] is only used if namedCertKeys[i] has no explicit names and may be nil.
+func resolveSNINames(namedCertKeys []cliflag.NamedCertKey, leafs []*x509.Certificate) (map[string]int, []error) {
+	claims := map[string][]sniClaim{}
+	var order []string
+	claim := func(name string, c sniClaim) {
+		name = strings.ToLower(name)
+		for _, existing := range claims[name] {
+			// a pair may list the same name twice
+			if existing.index == c.index {
+				return
+			}
+		}
+		if _, ok := claims[name]; !ok {
+			order = append(order, name)
+		}
+		claims[name] = append(claims[name], c)
+	}
+	for i, nck := range namedCertKeys {
+		if len(nck.Names) > 0 {
+			for _, name := range nck.Names {
+				claim(name, sniClaim{index: i, explicit: true})
+			}
+			continue
+		}
+		if i < len(leafs) && leafs[i] != nil {
+			for _, name := range CertificateNames(leafs[i]) {
+				claim(name, sniClaim{index: i})
+			}
+		}
+	}
+
+	nameToIndex := make(map[string]int, len(claims))
+	var errs []error
+	for _, name := range order {
+		cs := claims[name]
+		sort.SliceStable(cs, func(i, j int) bool { return cs[i].before(cs[j]) })
+		winner := cs[0]
+		nameToIndex[name] = winner.index
+		for _, loser := range cs[1:] {
+			errs = append(errs, fmt.Errorf("SNI name %q claimed %s by %q conflicts with %q, which claims it %s and takes precedence",
+				name, loser.kind(), namedCertKeys[loser.index].String(), namedCertKeys[winner.index].String(), winner.kind()))
+		}
+	}
+	return nameToIndex, errs
+}
@@ -0,0 +1,86 @@
+package cert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+func TestResolveSNINames(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	fooCert, fooKey := writeTestCertKey(t, dir, "foo", "foo", now, "foo.com", "*.foo.com", "10.0.0.1")
+	barCert, barKey := writeTestCertKey(t, dir, "bar", "bar.com", now)
+	bazCert, bazKey := writeTestCertKey(t, dir, "baz", "baz", now, "foo.com", "baz.com")
+
+	tests := []struct {
+		desc      string
+		ncks      []cliflag.NamedCertKey
+		expected  map[string]int
+		conflicts []string
+	}{
+		{
+			desc: "derived names",
+			ncks: []cliflag.NamedCertKey{
+				{CertFile: fooCert, KeyFile: fooKey},
+				{CertFile: barCert, KeyFile: barKey},
+			},
+			expected: map[string]int{"foo.com": 0, "*.foo.com": 0, "10.0.0.1": 0, "bar.com": 1},
+		},
+		{
+			desc: "explicit names beat derived ones",
+			ncks: []cliflag.NamedCertKey{
+				{CertFile: fooCert, KeyFile: fooKey},
+				{CertFile: bazCert, KeyFile: bazKey, Names: []string{"foo.com"}},
+			},
+			expected:  map[string]int{"foo.com": 1, "*.foo.com": 0, "10.0.0.1": 0},
+			conflicts: []string{`SNI name "foo.com" claimed by certificate by "` + fooCert + `,` + fooKey + `"`},
+		},
+		{
+			desc: "earlier flags beat later ones",
+			ncks: []cliflag.NamedCertKey{
+				{CertFile: bazCert, KeyFile: bazKey},
+				{CertFile: fooCert, KeyFile: fooKey},
+			},
+			expected:  map[string]int{"foo.com": 0, "baz.com": 0, "*.foo.com": 1, "10.0.0.1": 1},
+			conflicts: []string{`SNI name "foo.com" claimed by certificate by "` + fooCert + `,` + fooKey + `"`},
+		},
+		{
+			desc: "explicit conflicts",
+			ncks: []cliflag.NamedCertKey{
+				{CertFile: fooCert, KeyFile: fooKey, Names: []string{"a.com", "A.com"}},
+				{CertFile: barCert, KeyFile: barKey, Names: []string{"a.com"}},
+			},
+			expected:  map[string]int{"a.com": 0},
+			conflicts: []string{`SNI name "a.com" claimed explicitly by "` + barCert + `,` + barKey + `:a.com"`},
+		},
+		{
+			desc: "unreadable certificate",
+			ncks: []cliflag.NamedCertKey{
+				{CertFile: "missing.crt", KeyFile: "missing.key"},
+			},
+			expected:  map[string]int{},
+			conflicts: []string{`unable to load "missing.crt,missing.key"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			nameToIndex, errs := ResolveSNINames(test.ncks)
+			if !reflect.DeepEqual(nameToIndex, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, nameToIndex)
+			}
+			if len(errs) != len(test.conflicts) {
+				t.Fatalf("expected %d errors, got %v", len(test.conflicts), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), test.conflicts[i]) {
+					t.Errorf("expected error containing %q, got %q", test.conflicts[i], err.Error())
+				}
+			}
+		})
+	}
+}
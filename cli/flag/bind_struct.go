@@ -0,0 +1,291 @@
+package flag
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+// DefaultSection is the section of the flags bound by BindStruct which are neither in a nested
+// struct nor have a section tag.
+const DefaultSection = "generic"
+
+const (
+	// FlagTag is the struct tag of the flag name, "-" ignores the field. The name may be
+	// followed by ",repeated" for []string fields, see BindStruct.
+	FlagTag = "flag"
+	// UsageTag is the struct tag of the flag usage.
+	UsageTag = "usage"
+	// ShorthandTag is the struct tag of the one-letter flag shorthand.
+	ShorthandTag = "shorthand"
+	// SectionTag is the struct tag of the flag section, or of the section of a nested struct.
+	SectionTag = "section"
+)
+
+var (
+	pflagValueType      = reflect.TypeOf((*pflag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	namedCertKeysType   = reflect.TypeOf([]NamedCertKey(nil))
+)
+
+// BindStruct registers a flag for every field of the struct pointed to by opts which has a flag
+// tag, e.g.
+//
+//	type ServingOptions struct {
+//		BindPort int `flag:"secure-port" usage:"The port on which to serve HTTPS." section:"serving"`
+//	}
+//
+// The current value of the field is the default value of the flag. Fields without a flag tag
+// whose type is a struct, or a pointer to a struct, are bound recursively in their own section,
+// named by their section tag or by the words of the field name, e.g. "secure serving" for
+// SecureServing. Embedded structs are bound in the section of the struct that embeds them.
+// Unexported fields, including embedded structs of unexported types, are ignored.
+// Flags without a section tag outside any nested struct are in DefaultSection.
+//
+// The supported field types are the types which implement pflag.Value, such as Tristate and
+// StringFlag, non-nil pointers to them, such as *MapStringString, the types which implement
+// encoding.TextUnmarshaler, map[string]string (MapStringString), map[string]bool (MapStringBool),
+// map[string][]string (ColonSeparatedMultimapStringString), []NamedCertKey (NamedCertKeyArray),
+// []string, which is comma-separated, or accumulated by repeated flags (StringSlice) with the
+// ",repeated" option, and the bool, string, integer, float and time.Duration types.
+func BindStruct(nfs *NamedFlagSets, opts any) error {
+	v := reflect.ValueOf(opts)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expect a non-nil pointer to a struct but got %T", opts)
+	}
+	return bindStruct(nfs, v.Elem(), DefaultSection)
+}
+
+func bindStruct(nfs *NamedFlagSets, v reflect.Value, section string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, tagged := field.Tag.Lookup(FlagTag)
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if !tagged {
+			if err := bindNested(nfs, field, fv, section); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, option, _ := strings.Cut(tag, ",")
+		if len(name) == 0 {
+			return fmt.Errorf("empty flag name of field %s.%s", t.Name(), field.Name)
+		}
+		if option != "" && option != "repeated" {
+			return fmt.Errorf("unknown option %q of flag %q", option, name)
+		}
+		if lookupFlag(nfs, name) != nil {
+			return fmt.Errorf("flag %q of field %s.%s is already defined", name, t.Name(), field.Name)
+		}
+		flagSection := section
+		if s := field.Tag.Get(SectionTag); len(s) > 0 {
+			flagSection = s
+		}
+		value, err := fieldValue(fv, option == "repeated")
+		if err != nil {
+			return fmt.Errorf("flag %q of field %s.%s: %v", name, t.Name(), field.Name, err)
+		}
+		fs := nfs.FlagSet(flagSection)
+		fs.VarP(value, name, field.Tag.Get(ShorthandTag), field.Tag.Get(UsageTag))
+		if value.Type() == "bool" {
+			fs.Lookup(name).NoOptDefVal = "true"
+		}
+	}
+	return nil
+}
+
+// bindNested binds the untagged struct field, other fields and the structs which are values,
+// e.g. time.Time, are ignored.
+func bindNested(nfs *NamedFlagSets, field reflect.StructField, fv reflect.Value, section string) error {
+	ft := field.Type
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct {
+		return nil
+	}
+	if pt := reflect.PointerTo(ft); pt.Implements(pflagValueType) || pt.Implements(textUnmarshalerType) {
+		return nil
+	}
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(ft))
+		}
+		fv = fv.Elem()
+	}
+	if s := field.Tag.Get(SectionTag); len(s) > 0 {
+		section = s
+	} else if !field.Anonymous {
+		section = sectionName(field.Name)
+	}
+	return bindStruct(nfs, fv, section)
+}
+
+// fieldValue returns the pflag.Value which sets the field.
+func fieldValue(fv reflect.Value, repeated bool) (pflag.Value, error) {
+	ft := fv.Type()
+	if repeated && ft != reflect.TypeOf([]string(nil)) {
+		return nil, fmt.Errorf("the repeated option is only supported by []string but got %s", ft)
+	}
+	ptr := fv.Addr()
+	switch {
+	case ptr.Type().Implements(pflagValueType):
+		return ptr.Interface().(pflag.Value), nil
+	case ft.Kind() == reflect.Pointer && ft.Implements(pflagValueType):
+		if fv.IsNil() {
+			return nil, fmt.Errorf("nil %s", ft)
+		}
+		return fv.Interface().(pflag.Value), nil
+	case ptr.Type().Implements(textUnmarshalerType):
+		return &textValue{value: ptr}, nil
+	case ft == durationType:
+		return newValue((*pflag.FlagSet).DurationVar, ptr)
+	case ft == namedCertKeysType:
+		return NewNamedCertKeyArray(ptr.Interface().(*[]NamedCertKey)), nil
+	}
+
+	switch ft.Kind() {
+	case reflect.Bool:
+		return newValue((*pflag.FlagSet).BoolVar, ptr)
+	case reflect.String:
+		return newValue((*pflag.FlagSet).StringVar, ptr)
+	case reflect.Int:
+		return newValue((*pflag.FlagSet).IntVar, ptr)
+	case reflect.Int8:
+		return newValue((*pflag.FlagSet).Int8Var, ptr)
+	case reflect.Int16:
+		return newValue((*pflag.FlagSet).Int16Var, ptr)
+	case reflect.Int32:
+		return newValue((*pflag.FlagSet).Int32Var, ptr)
+	case reflect.Int64:
+		return newValue((*pflag.FlagSet).Int64Var, ptr)
+	case reflect.Uint:
+		return newValue((*pflag.FlagSet).UintVar, ptr)
+	case reflect.Uint8:
+		return newValue((*pflag.FlagSet).Uint8Var, ptr)
+	case reflect.Uint16:
+		return newValue((*pflag.FlagSet).Uint16Var, ptr)
+	case reflect.Uint32:
+		return newValue((*pflag.FlagSet).Uint32Var, ptr)
+	case reflect.Uint64:
+		return newValue((*pflag.FlagSet).Uint64Var, ptr)
+	case reflect.Float32:
+		return newValue((*pflag.FlagSet).Float32Var, ptr)
+	case reflect.Float64:
+		return newValue((*pflag.FlagSet).Float64Var, ptr)
+	case reflect.Slice:
+		switch ft.Elem().Kind() {
+		case reflect.String:
+			if p, ok := convertPointer[[]string](ptr); ok && repeated {
+				return NewStringSlice(p), nil
+			}
+			return newValue((*pflag.FlagSet).StringSliceVar, ptr)
+		case reflect.Int:
+			return newValue((*pflag.FlagSet).IntSliceVar, ptr)
+		}
+	case reflect.Map:
+		if p, ok := convertPointer[map[string]string](ptr); ok {
+			return NewMapStringString(p), nil
+		}
+		if p, ok := convertPointer[map[string]bool](ptr); ok {
+			return NewMapStringBool(p), nil
+		}
+		if p, ok := convertPointer[map[string][]string](ptr); ok {
+			return NewColonSeparatedMultimapStringString(p), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", ft)
+}
+
+// newValue defines a flag in a scratch flag set with the pflag XxxVar method, so that the value
+// types of pflag are reused, and returns its value. The default value is the current value.
+func newValue[T any](varFunc func(fs *pflag.FlagSet, p *T, name string, value T, usage string),
+	ptr reflect.Value) (pflag.Value, error) {
+	p, ok := convertPointer[T](ptr)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %s", ptr.Type().Elem())
+	}
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	varFunc(fs, p, "value", *p, "")
+	return fs.Lookup("value").Value, nil
+}
+
+// convertPointer converts the pointer to a named type to a pointer to T, if their underlying
+// types are identical, e.g. *Mode to *string for "type Mode string".
+func convertPointer[T any](ptr reflect.Value) (*T, bool) {
+	t := reflect.TypeOf((*T)(nil))
+	if !ptr.CanConvert(t) {
+		return nil, false
+	}
+	return ptr.Convert(t).Interface().(*T), true
+}
+
+// textValue implements pflag.Value for the types which implement encoding.TextUnmarshaler.
+type textValue struct {
+	value reflect.Value
+}
+
+func (v *textValue) String() string {
+	if m, ok := v.value.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.value.Elem().Interface())
+}
+
+func (v *textValue) Set(value string) error {
+	return v.value.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+}
+
+func (v *textValue) Type() string {
+	if name := v.value.Type().Elem().Name(); len(name) > 0 {
+		return strings.ToLower(name)
+	}
+	return "string"
+}
+
+// lookupFlag returns the flag with the given name in any of the flag sets.
+func lookupFlag(nfs *NamedFlagSets, name string) *pflag.Flag {
+	for _, fs := range nfs.FlagSets {
+		if flag := fs.Lookup(name); flag != nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+// sectionName returns the lower-cased words of the camel-cased field name, e.g. "secure serving"
+// for SecureServing and "tls options" for TLSOptions.
+func sectionName(fieldName string) string {
+	runes := []rune(fieldName)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		// a new word starts at an upper case letter after a lower case one,
+		// or at the last upper case letter of an acronym followed by a lower case one.
+		if !unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+	return strings.ToLower(strings.Join(words, " "))
+}
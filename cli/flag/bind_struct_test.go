@@ -0,0 +1,161 @@
+package flag
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type bindMode string
+
+type bindServingOptions struct {
+	BindAddress net.IP         `flag:"bind-address" usage:"The IP address on which to listen."`
+	BindPort    int            `flag:"secure-port" shorthand:"p" usage:"The port on which to serve HTTPS."`
+	CertKeys    []NamedCertKey `flag:"tls-sni-cert-key"`
+	Timeout     time.Duration  `flag:"request-timeout" section:"generic"`
+}
+
+// BindLogOptions is exported to be embedded, the fields of unexported embedded structs are ignored.
+type BindLogOptions struct {
+	Level string `flag:"log-level"`
+}
+
+type bindOptions struct {
+	BindLogOptions
+
+	Debug      bool                `flag:"debug"`
+	Mode       bindMode            `flag:"mode"`
+	Names      []string            `flag:"names"`
+	Commands   []string            `flag:"command,repeated"`
+	Labels     map[string]string   `flag:"labels"`
+	Gates      map[string]bool     `flag:"feature-gates"`
+	Multimap   map[string][]string `flag:"multimap"`
+	Enabled    Tristate            `flag:"enabled"`
+	Token      StringFlag          `flag:"token"`
+	Ignored    string              `flag:"-"`
+	Untagged   string
+	TLSServing *bindServingOptions
+	Cache      struct {
+		Size uint `flag:"cache-size"`
+	} `section:"caching"`
+}
+
+func TestBindStruct(t *testing.T) {
+	o := &bindOptions{Mode: "fast", Names: []string{"a"}}
+	o.Level = "info"
+	nfs := NamedFlagSets{}
+	if err := BindStruct(&nfs, o); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"generic", "tls serving", "caching"}; !reflect.DeepEqual(nfs.Order, expect) {
+		t.Fatalf("expect sections %v but got %v", expect, nfs.Order)
+	}
+	sections := map[string][]string{
+		"generic": {"command", "debug", "enabled", "feature-gates", "labels", "log-level", "mode",
+			"multimap", "names", "request-timeout", "token"},
+		"tls serving": {"bind-address", "secure-port", "tls-sni-cert-key"},
+		"caching":     {"cache-size"},
+	}
+	for section, expect := range sections {
+		var got []string
+		nfs.FlagSets[section].VisitAll(func(flag *pflag.Flag) { got = append(got, flag.Name) })
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("expect flags %v in section %q but got %v", expect, section, got)
+		}
+	}
+	if flag := nfs.FlagSets["generic"].Lookup("mode"); flag.DefValue != "fast" {
+		t.Errorf("expect the default value fast but got %q", flag.DefValue)
+	}
+	if flag := nfs.FlagSets["tls serving"].Lookup("secure-port"); flag.Shorthand != "p" ||
+		flag.Usage != "The port on which to serve HTTPS." {
+		t.Errorf("unexpected flag %+v", flag)
+	}
+
+	args := map[string][]string{
+		"generic": {"--debug", "--mode=slow", "--names=b,c", "--command=x,y", "--command=z",
+			"--labels=a=1,b=2", "--feature-gates=A=true", "--multimap=k:v1,k:v2", "--enabled=false",
+			"--token=secret", "--log-level=debug", "--request-timeout=1m"},
+		"tls serving": {"-p", "8443", "--bind-address=10.0.0.1", "--tls-sni-cert-key=a.crt,a.key"},
+		"caching":     {"--cache-size=10"},
+	}
+	for section, a := range args {
+		if err := nfs.FlagSets[section].Parse(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := &bindOptions{
+		BindLogOptions: BindLogOptions{Level: "debug"},
+		Debug:          true,
+		Mode:           "slow",
+		Names:          []string{"b", "c"},
+		Commands:       []string{"x,y", "z"},
+		Labels:         map[string]string{"a": "1", "b": "2"},
+		Gates:          map[string]bool{"A": true},
+		Multimap:       map[string][]string{"k": {"v1", "v2"}},
+		Enabled:        False,
+		TLSServing: &bindServingOptions{
+			BindAddress: net.ParseIP("10.0.0.1"),
+			BindPort:    8443,
+			CertKeys:    []NamedCertKey{{CertFile: "a.crt", KeyFile: "a.key"}},
+			Timeout:     time.Minute,
+		},
+	}
+	expect.Token.Set("secret")
+	expect.Cache.Size = 10
+	if !reflect.DeepEqual(o, expect) {
+		t.Fatalf("expect %+v but got %+v", expect, o)
+	}
+}
+
+func TestBindStructErrors(t *testing.T) {
+	cases := []struct {
+		desc   string
+		opts   any
+		expect string
+	}{
+		{"not a pointer", BindLogOptions{}, "expect a non-nil pointer to a struct"},
+		{"nil pointer", (*BindLogOptions)(nil), "expect a non-nil pointer to a struct"},
+		{"unsupported type", &struct {
+			C chan int `flag:"c"`
+		}{}, `flag "c" of field .C: unsupported type chan int`},
+		{"empty name", &struct {
+			C string `flag:",repeated"`
+		}{}, "empty flag name"},
+		{"repeated non-slice", &struct {
+			C string `flag:"c,repeated"`
+		}{}, "the repeated option is only supported by []string"},
+		{"nil value", &struct {
+			C *MapStringString `flag:"c"`
+		}{}, "nil *flag.MapStringString"},
+		{"duplicate", &struct {
+			A string `flag:"a"`
+			B string `flag:"a" section:"other"`
+		}{}, `flag "a" of field .B is already defined`},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := BindStruct(&NamedFlagSets{}, c.opts)
+			if err == nil || !strings.Contains(err.Error(), c.expect) {
+				t.Fatalf("expect error %q but got %v", c.expect, err)
+			}
+		})
+	}
+}
+
+func TestSectionName(t *testing.T) {
+	cases := map[string]string{
+		"SecureServing": "secure serving",
+		"TLSServing":    "tls serving",
+		"Logs":          "logs",
+		"APIServerTLS":  "api server tls",
+	}
+	for name, expect := range cases {
+		if got := sectionName(name); got != expect {
+			t.Errorf("expect %q for %s but got %q", expect, name, got)
+		}
+	}
+}
@@ -38,22 +38,23 @@ func InsecureTLSCiphers() map[string]uint16 {
 }
 
 // InsecureTLSCipherNames returns a list of cipher suite names implemented by crypto/tls
-// which have security issues.
+// which have security issues. In FIPS mode, only the approved names are returned.
 func InsecureTLSCipherNames() []string {
 	cipherKeys := sets.NewString()
 	for key := range insecureCiphers {
 		cipherKeys.Insert(key)
 	}
-	return cipherKeys.List()
+	return fipsAllowed(cipherKeys.List(), fipsCiphers)
 }
 
 // PreferredTLSCipherNames returns a list of cipher suite names implemented by crypto/tls.
+// In FIPS mode, only the approved names are returned.
 func PreferredTLSCipherNames() []string {
 	cipherKeys := sets.NewString()
 	for key := range ciphers {
 		cipherKeys.Insert(key)
 	}
-	return cipherKeys.List()
+	return fipsAllowed(cipherKeys.List(), fipsCiphers)
 }
 
 func allCiphers() map[string]uint16 {
@@ -69,16 +70,18 @@ func allCiphers() map[string]uint16 {
 
 // TLSCipherPossibleValues returns all acceptable cipher suite names.
 // This is a combination of both InsecureTLSCipherNames() and PreferredTLSCipherNames().
+// In FIPS mode, only the approved names are returned.
 func TLSCipherPossibleValues() []string {
 	cipherKeys := sets.NewString()
 	acceptedCiphers := allCiphers()
 	for key := range acceptedCiphers {
 		cipherKeys.Insert(key)
 	}
-	return cipherKeys.List()
+	return fipsAllowed(cipherKeys.List(), fipsCiphers)
 }
 
 // TLSCipherSuites returns a list of cipher suite IDs from the cipher suite names passed.
+// In FIPS mode, the names which are not approved are rejected.
 func TLSCipherSuites(cipherNames []string) ([]uint16, error) {
 	if len(cipherNames) == 0 {
 		return nil, nil
@@ -92,6 +95,9 @@ func TLSCipherSuites(cipherNames []string) ([]uint16, error) {
 		}
 		ciphersIntSlice = append(ciphersIntSlice, intValue)
 	}
+	if err := fipsRejected("cipher suites", cipherNames, fipsCiphers); err != nil {
+		return nil, err
+	}
 	return ciphersIntSlice, nil
 }
 
@@ -103,20 +109,25 @@ var versions = map[string]uint16{
 }
 
 // TLSPossibleVersions returns all acceptable values for TLS Version.
+// In FIPS mode, only the approved versions are returned.
 func TLSPossibleVersions() []string {
 	versionsKeys := sets.NewString()
 	for key := range versions {
 		versionsKeys.Insert(key)
 	}
-	return versionsKeys.List()
+	return fipsAllowed(versionsKeys.List(), fipsVersions)
 }
 
 // TLSVersion returns the TLS Version ID for the version name passed.
+// In FIPS mode, the versions which are not approved are rejected.
 func TLSVersion(versionName string) (uint16, error) {
 	if len(versionName) == 0 {
 		return DefaultTLSVersion(), nil
 	}
 	if version, ok := versions[versionName]; ok {
+		if err := fipsRejected("tls versions", []string{versionName}, fipsVersions); err != nil {
+			return 0, err
+		}
 		return version, nil
 	}
 	return 0, fmt.Errorf("unknown tls version %q", versionName)
@@ -0,0 +1,234 @@
+package flag
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/pflag"
+)
+
+// TLSCipherHelpValue is the value of a cipher suites flag which prints
+// the information of all cipher suites and exits.
+const TLSCipherHelpValue = "help"
+
+// TLSCipherInfo describes a cipher suite implemented by crypto/tls.
+type TLSCipherInfo struct {
+	// ID is the cipher suite ID as defined by IANA.
+	ID uint16
+	// Name is the canonical name of the cipher suite.
+	Name string
+	// LegacyName is the name kept for backward compatibility, it may be empty.
+	LegacyName string
+	// SupportedVersions are the TLS version names which can negotiate the cipher suite.
+	SupportedVersions []string
+	// KeyExchange is the key exchange and authentication mechanism, e.g. "ECDHE-RSA".
+	// TLS 1.3 cipher suites don't define a key exchange, so it is "any" for them.
+	KeyExchange string
+	// AEAD is true if the cipher suite uses an AEAD cipher.
+	AEAD bool
+	// Insecure is true if the cipher suite has known security issues.
+	Insecure bool
+}
+
+var (
+	// legacyCipherNames maps the canonical names into the names kept for backward compatibility.
+	legacyCipherNames = map[string]string{
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305",
+	}
+	// keyExchanges maps the cipher suite name prefixes into the key exchange mechanisms.
+	keyExchanges = []struct {
+		prefix      string
+		keyExchange string
+	}{
+		{prefix: "TLS_ECDHE_ECDSA_", keyExchange: "ECDHE-ECDSA"},
+		{prefix: "TLS_ECDHE_RSA_", keyExchange: "ECDHE-RSA"},
+		{prefix: "TLS_RSA_", keyExchange: "RSA"},
+	}
+)
+
+// TLSCipherInfos returns the information of all cipher suites implemented by crypto/tls,
+// sorted by name. In FIPS mode, only the approved cipher suites are returned.
+func TLSCipherInfos() []TLSCipherInfo {
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	infos := make([]TLSCipherInfo, 0, len(suites))
+	for _, suite := range suites {
+		if TLSFIPSMode() && !fipsCiphers.Has(suite.Name) {
+			continue
+		}
+		infos = append(infos, newTLSCipherInfo(suite))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// TLSCipherInfoByName returns the information of the cipher suite with the given
+// canonical or legacy name.
+func TLSCipherInfoByName(name string) (TLSCipherInfo, bool) {
+	id, ok := allCiphers()[name]
+	if !ok {
+		return TLSCipherInfo{}, false
+	}
+	return TLSCipherInfoByID(id)
+}
+
+// TLSCipherInfoByID returns the information of the cipher suite with the given ID.
+func TLSCipherInfoByID(id uint16) (TLSCipherInfo, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.ID == id {
+			return newTLSCipherInfo(suite), true
+		}
+	}
+	return TLSCipherInfo{}, false
+}
+
+// TLSCipherName returns the canonical name of the cipher suite ID, e.g. for logging the
+// cipher suite of a negotiated connection. If the ID is not implemented by crypto/tls,
+// its hex representation is returned.
+func TLSCipherName(id uint16) string {
+	return tls.CipherSuiteName(id)
+}
+
+// PrintTLSCipherInfos prints the information of all cipher suites as a table.
+func PrintTLSCipherInfos(w io.Writer) {
+	table := uitable.New()
+	table.Separator = "  "
+	table.AddRow("NAME", "ID", "LEGACY NAME", "VERSIONS", "KEY EXCHANGE", "AEAD", "INSECURE")
+	for _, info := range TLSCipherInfos() {
+		table.AddRow(info.Name, fmt.Sprintf("0x%04X", info.ID), info.LegacyName,
+			strings.Join(info.SupportedVersions, ","), info.KeyExchange, info.AEAD, info.Insecure)
+	}
+	_, _ = fmt.Fprintln(w, table)
+}
+
+func newTLSCipherInfo(suite *tls.CipherSuite) TLSCipherInfo {
+	info := TLSCipherInfo{
+		ID:          suite.ID,
+		Name:        suite.Name,
+		LegacyName:  legacyCipherNames[suite.Name],
+		KeyExchange: "any",
+		AEAD:        strings.Contains(suite.Name, "_GCM_") || strings.Contains(suite.Name, "_CHACHA20_POLY1305"),
+		Insecure:    suite.Insecure,
+	}
+	for _, kx := range keyExchanges {
+		if strings.HasPrefix(suite.Name, kx.prefix) {
+			info.KeyExchange = kx.keyExchange
+			break
+		}
+	}
+	for _, v := range suite.SupportedVersions {
+		info.SupportedVersions = append(info.SupportedVersions, tlsVersionName(v))
+	}
+	return info
+}
+
+// tlsVersionName returns the name of the TLS version ID.
+func tlsVersionName(version uint16) string {
+	for name, v := range versions {
+		if v == version {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// TLSCipherSuitesValue is a comma-separated list of cipher suite names, it prints the
+// information of all cipher suites and exits if the value is TLSCipherHelpValue. The help
+// value is only allowed on the command line, see CommandLineOnly.
+type TLSCipherSuitesValue struct {
+	value   *[]string
+	changed bool
+	// Out is the destination of the cipher suites table, os.Stdout is used if it is nil.
+	Out io.Writer
+	// Exit is called after printing the cipher suites table, os.Exit is used if it is nil.
+	Exit func(code int)
+}
+
+var _ pflag.Value = &TLSCipherSuitesValue{}
+var _ pflag.SliceValue = &TLSCipherSuitesValue{}
+var _ EnumValue = &TLSCipherSuitesValue{}
+
+// NewTLSCipherSuitesValue creates a new TLSCipherSuitesValue with the internal value
+// pointing to p.
+func NewTLSCipherSuitesValue(p *[]string) *TLSCipherSuitesValue {
+	return &TLSCipherSuitesValue{value: p}
+}
+
+// String implements github.com/spf13/pflag.Value
+func (v *TLSCipherSuitesValue) String() string {
+	if v == nil || v.value == nil {
+		return ""
+	}
+	return strings.Join(*v.value, ",")
+}
+
+// Set implements github.com/spf13/pflag.Value
+func (v *TLSCipherSuitesValue) Set(value string) error {
+	if strings.TrimSpace(value) == TLSCipherHelpValue {
+		out, exit := v.Out, v.Exit
+		if out == nil {
+			out = os.Stdout
+		}
+		if exit == nil {
+			exit = os.Exit
+		}
+		PrintTLSCipherInfos(out)
+		exit(0)
+		return nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	if !v.changed {
+		*v.value = names
+		v.changed = true
+	} else {
+		*v.value = append(*v.value, names...)
+	}
+	return nil
+}
+
+// CommandLineOnly returns an error if the value is TLSCipherHelpValue, which must not stop
+// the process when it is set from other sources, e.g. environment variables and config files.
+func (*TLSCipherSuitesValue) CommandLineOnly(value string) error {
+	if strings.TrimSpace(value) == TLSCipherHelpValue {
+		return fmt.Errorf("%q is only allowed on the command line", TLSCipherHelpValue)
+	}
+	return nil
+}
+
+// Type implements github.com/spf13/pflag.Value
+func (*TLSCipherSuitesValue) Type() string {
+	return "strings"
+}
+
+// Append implements github.com/spf13/pflag.SliceValue
+func (v *TLSCipherSuitesValue) Append(value string) error {
+	*v.value = append(*v.value, value)
+	return nil
+}
+
+// Replace implements github.com/spf13/pflag.SliceValue
+func (v *TLSCipherSuitesValue) Replace(values []string) error {
+	*v.value = append([]string{}, values...)
+	return nil
+}
+
+// GetSlice implements github.com/spf13/pflag.SliceValue
+func (v *TLSCipherSuitesValue) GetSlice() []string {
+	return *v.value
+}
+
+// PossibleValues implements EnumValue, the names of TLSCipherPossibleValues.
+func (*TLSCipherSuitesValue) PossibleValues() []string {
+	return TLSCipherPossibleValues()
+}
@@ -0,0 +1,102 @@
+package flag
+
+import (
+	"bytes"
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestTLSCipherInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected TLSCipherInfo
+	}{
+		{
+			name: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
+			expected: TLSCipherInfo{
+				ID:                tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+				Name:              "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+				LegacyName:        "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
+				SupportedVersions: []string{"VersionTLS12"},
+				KeyExchange:       "ECDHE-RSA",
+				AEAD:              true,
+			},
+		},
+		{
+			name: "TLS_AES_128_GCM_SHA256",
+			expected: TLSCipherInfo{
+				ID:                tls.TLS_AES_128_GCM_SHA256,
+				Name:              "TLS_AES_128_GCM_SHA256",
+				SupportedVersions: []string{"VersionTLS13"},
+				KeyExchange:       "any",
+				AEAD:              true,
+			},
+		},
+		{
+			name: "TLS_RSA_WITH_AES_128_CBC_SHA256",
+			expected: TLSCipherInfo{
+				ID:                tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+				Name:              "TLS_RSA_WITH_AES_128_CBC_SHA256",
+				SupportedVersions: []string{"VersionTLS12"},
+				KeyExchange:       "RSA",
+				Insecure:          true,
+			},
+		},
+	}
+	for _, test := range tests {
+		info, ok := TLSCipherInfoByName(test.name)
+		if !ok {
+			t.Fatalf("%s: expected the cipher suite to be found", test.name)
+		}
+		if !reflect.DeepEqual(info, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, info)
+		}
+		if name := TLSCipherName(info.ID); name != test.expected.Name {
+			t.Errorf("%s: expected name %q for ID, got %q", test.name, test.expected.Name, name)
+		}
+	}
+
+	if _, ok := TLSCipherInfoByName("foo"); ok {
+		t.Errorf("expected an unknown cipher suite not to be found")
+	}
+	if name := TLSCipherName(0x0001); name != "0x0001" {
+		t.Errorf("expected the hex representation of an unknown ID, got %q", name)
+	}
+	if infos := TLSCipherInfos(); len(infos) != len(tls.CipherSuites())+len(tls.InsecureCipherSuites()) {
+		t.Errorf("expected the information of all cipher suites, got %d", len(infos))
+	}
+}
+
+func TestTLSCipherSuitesValue(t *testing.T) {
+	var ciphers []string
+	v := NewTLSCipherSuitesValue(&ciphers)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Var(v, "tls-cipher-suites", "")
+
+	args := []string{"--tls-cipher-suites=TLS_AES_128_GCM_SHA256, TLS_AES_256_GCM_SHA384", "--tls-cipher-suites=TLS_CHACHA20_POLY1305_SHA256"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"TLS_AES_128_GCM_SHA256", "TLS_AES_256_GCM_SHA384", "TLS_CHACHA20_POLY1305_SHA256"}
+	if !reflect.DeepEqual(ciphers, expected) {
+		t.Errorf("expected %v, got %v", expected, ciphers)
+	}
+
+	var out bytes.Buffer
+	exitCode := -1
+	v.Out = &out
+	v.Exit = func(code int) { exitCode = code }
+	if err := fs.Parse([]string{"--tls-cipher-suites=help"}); err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", exitCode)
+	}
+	if !strings.HasPrefix(out.String(), "NAME") || !strings.Contains(out.String(), "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256") {
+		t.Errorf("expected the cipher suites table, got %q", out.String())
+	}
+}
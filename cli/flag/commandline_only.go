@@ -0,0 +1,8 @@
+package flag
+
+// CommandLineOnly is an interface for flags to reject the values which are only allowed on the
+// command line, e.g. the values which print something and exit. The values set from environment
+// variables and config files are checked by it before they are set.
+type CommandLineOnly interface {
+	CommandLineOnly(value string) error
+}
@@ -0,0 +1,55 @@
+package flag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnumValue is an interface for flags to report the possible values of their underlying value,
+// or of each element of a slice value. It is used to document the flags, e.g. in JSON schemas.
+type EnumValue interface {
+	PossibleValues() []string
+}
+
+// Enum is a string flag whose value must be one of the possible values.
+type Enum struct {
+	value    *string
+	possible []string
+}
+
+var _ EnumValue = &Enum{}
+
+// NewEnum takes a pointer to a string and the possible values of the string and returns
+// the Enum flag parsing shim for that string.
+func NewEnum(p *string, possibleValues ...string) *Enum {
+	return &Enum{value: p, possible: possibleValues}
+}
+
+// String implements github.com/spf13/pflag.Value
+func (e *Enum) String() string {
+	if e == nil || e.value == nil {
+		return ""
+	}
+	return *e.value
+}
+
+// Set implements github.com/spf13/pflag.Value
+func (e *Enum) Set(value string) error {
+	for _, possible := range e.possible {
+		if value == possible {
+			*e.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of the possible values: %s", value, strings.Join(e.possible, ", "))
+}
+
+// Type implements github.com/spf13/pflag.Value
+func (*Enum) Type() string {
+	return "string"
+}
+
+// PossibleValues implements EnumValue
+func (e *Enum) PossibleValues() []string {
+	return e.possible
+}
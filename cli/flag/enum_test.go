@@ -0,0 +1,39 @@
+package flag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestEnum(t *testing.T) {
+	cases := []struct {
+		desc   string
+		args   []string
+		expect string
+		err    string
+	}{
+		{"default", nil, "table", ""},
+		{"possible value", []string{"--format=json"}, "json", ""},
+		{"impossible value", []string{"--format=yaml"}, "table", `"yaml" is not one of the possible values: table, json`},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			format := "table"
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			fs.Var(NewEnum(&format, "table", "json"), "format", "")
+			err := fs.Parse(c.args)
+			if len(c.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expect error %q but got %v", c.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if format != c.expect {
+				t.Fatalf("expect %q but got %q", c.expect, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// EnvAnnotation is the flag annotation which records the environment variable
+// a flag value has been set from.
+const EnvAnnotation = "cliflag.env"
+
+var envReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// EnvVarName returns the name of the environment variable which sets the flag with the given name,
+// e.g. "MYAPP_SECURE_PORT" for the prefix "MYAPP" and the flag "secure-port".
+// It returns an empty string if EnvPrefix is empty.
+func (nfs *NamedFlagSets) EnvVarName(flagName string) string {
+	if len(nfs.EnvPrefix) == 0 {
+		return ""
+	}
+	return strings.ToUpper(envReplacer.Replace(nfs.EnvPrefix + "_" + flagName))
+}
+
+// SetFromEnv sets the flags which have not been set on the command line from their
+// environment variables, see EnvVarName. It should be called after the command line
+// is parsed, so the precedence is command line over environment over default.
+// The values are set through the Set method of each flag, the flags keep their
+// Changed state, and the environment variable is recorded in the EnvAnnotation.
+func (nfs *NamedFlagSets) SetFromEnv() error {
+	if len(nfs.EnvPrefix) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range nfs.Order {
+		nfs.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if flag.Changed {
+				return
+			}
+			envName := nfs.EnvVarName(flag.Name)
+			value, ok := os.LookupEnv(envName)
+			if !ok {
+				return
+			}
+			if v, ok := flag.Value.(CommandLineOnly); ok {
+				if err := v.CommandLineOnly(value); err != nil {
+					errs = append(errs, fmt.Errorf("invalid value %q for environment variable %s of flag --%s: %v", value, envName, flag.Name, err))
+					return
+				}
+			}
+			if err := flag.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for environment variable %s of flag --%s: %v", value, envName, flag.Name, err))
+				return
+			}
+			if flag.Annotations == nil {
+				flag.Annotations = map[string][]string{}
+			}
+			flag.Annotations[EnvAnnotation] = []string{envName}
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// FlagSetFromEnv returns the environment variable a flag value has been set from by SetFromEnv.
+func FlagSetFromEnv(flag *pflag.Flag) (string, bool) {
+	if envNames, ok := flag.Annotations[EnvAnnotation]; ok && len(envNames) > 0 {
+		return envNames[0], true
+	}
+	return "", false
+}
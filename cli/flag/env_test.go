@@ -0,0 +1,122 @@
+package flag
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvVarName(t *testing.T) {
+	cases := []struct {
+		desc   string
+		prefix string
+		flag   string
+		expect string
+	}{
+		{"no prefix", "", "secure-port", ""},
+		{"dashes", "MYAPP", "secure-port", "MYAPP_SECURE_PORT"},
+		{"dots", "myapp", "log.level", "MYAPP_LOG_LEVEL"},
+		{"dashed prefix", "my-app", "port", "MY_APP_PORT"},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			nfs := NamedFlagSets{EnvPrefix: c.prefix}
+			if got := nfs.EnvVarName(c.flag); got != c.expect {
+				t.Fatalf("expect %q but got %q", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestSetFromEnv(t *testing.T) {
+	var (
+		port     int
+		host     string
+		labels   map[string]string
+		certKeys []NamedCertKey
+	)
+	nfs := NamedFlagSets{EnvPrefix: "MYAPP"}
+	fs := nfs.FlagSet("secure serving")
+	fs.IntVar(&port, "secure-port", 443, "")
+	fs.StringVar(&host, "bind-address", "0.0.0.0", "")
+	fs.Var(NewNamedCertKeyArray(&certKeys), "tls-sni-cert-key", "")
+	nfs.FlagSet("generic").Var(NewMapStringString(&labels), "labels", "")
+
+	t.Setenv("MYAPP_SECURE_PORT", "8443")
+	t.Setenv("MYAPP_BIND_ADDRESS", "127.0.0.1")
+	t.Setenv("MYAPP_TLS_SNI_CERT_KEY", "foo.crt,foo.key:foo.com")
+	t.Setenv("MYAPP_LABELS", "a=b,c=d")
+
+	if err := fs.Parse([]string{"--bind-address=10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nfs.SetFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	if port != 8443 {
+		t.Errorf("expect port 8443 from env but got %d", port)
+	}
+	if host != "10.0.0.1" {
+		t.Errorf("expect the command line value 10.0.0.1 but got %s", host)
+	}
+	expectCertKeys := []NamedCertKey{{CertFile: "foo.crt", KeyFile: "foo.key", Names: []string{"foo.com"}}}
+	if !reflect.DeepEqual(certKeys, expectCertKeys) {
+		t.Errorf("expect %v but got %v", expectCertKeys, certKeys)
+	}
+	if !reflect.DeepEqual(labels, map[string]string{"a": "b", "c": "d"}) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+
+	portFlag := fs.Lookup("secure-port")
+	if portFlag.Changed {
+		t.Errorf("expect the flag set from env not to be changed")
+	}
+	if envName, ok := FlagSetFromEnv(portFlag); !ok || envName != "MYAPP_SECURE_PORT" {
+		t.Errorf("expect the env annotation MYAPP_SECURE_PORT but got %q", envName)
+	}
+	if _, ok := FlagSetFromEnv(fs.Lookup("bind-address")); ok {
+		t.Errorf("expect no env annotation for the command line flag")
+	}
+}
+
+func TestSetFromEnvError(t *testing.T) {
+	var port int
+	nfs := NamedFlagSets{EnvPrefix: "MYAPP"}
+	nfs.FlagSet("generic").IntVar(&port, "port", 80, "")
+	t.Setenv("MYAPP_PORT", "abc")
+
+	err := nfs.SetFromEnv()
+	if err == nil || !strings.Contains(err.Error(), "MYAPP_PORT of flag --port") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestPrintSectionsEnv(t *testing.T) {
+	var port int
+	nfs := NamedFlagSets{EnvPrefix: "MYAPP"}
+	nfs.FlagSet("generic").IntVar(&port, "port", 80, "The port to listen on.")
+
+	var buf bytes.Buffer
+	PrintSections(&buf, nfs, 0)
+	if !strings.Contains(buf.String(), "The port to listen on. [env: MYAPP_PORT] (default 80)") {
+		t.Fatalf("expect the env var name in the usage, got %q", buf.String())
+	}
+	if usage := nfs.FlagSets["generic"].Lookup("port").Usage; usage != "The port to listen on." {
+		t.Fatalf("expect the usage not to be changed, got %q", usage)
+	}
+}
+
+func TestSetFromEnvCommandLineOnly(t *testing.T) {
+	var ciphers []string
+	v := NewTLSCipherSuitesValue(&ciphers)
+	v.Exit = func(int) { t.Fatal("expected no exit for the environment variable") }
+	nfs := NamedFlagSets{EnvPrefix: "TESTAPP"}
+	nfs.FlagSet("secure serving").Var(v, "tls-cipher-suites", "")
+	t.Setenv("TESTAPP_TLS_CIPHER_SUITES", "help")
+
+	if err := nfs.SetFromEnv(); err == nil || !strings.Contains(err.Error(), `"help" is only allowed on the command line`) {
+		t.Errorf("expected the help value to be rejected, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"flag"
 	"strings"
+
+	"github.com/spf13/pflag"
 )
 
 // NamedCertKey is a flag value parsing "certfile,keyfile" and "certfile,keyfile:name,name,name".
@@ -60,6 +62,7 @@ type NamedCertKeyArray struct {
 }
 
 var _ flag.Value = &NamedCertKeyArray{}
+var _ pflag.SliceValue = &NamedCertKeyArray{}
 
 // NewNamedCertKeyArray creates a new NamedCertKeyArray with the internal value
 // pointing to p.
@@ -95,3 +98,36 @@ func (a *NamedCertKeyArray) String() string {
 	}
 	return "[" + strings.Join(nkcs, ";") + "]"
 }
+
+// Append implements github.com/spf13/pflag.SliceValue
+func (a *NamedCertKeyArray) Append(val string) error {
+	nkc := NamedCertKey{}
+	if err := nkc.Set(val); err != nil {
+		return err
+	}
+	*a.value = append(*a.value, nkc)
+	return nil
+}
+
+// Replace implements github.com/spf13/pflag.SliceValue
+func (a *NamedCertKeyArray) Replace(vals []string) error {
+	nkcs := make([]NamedCertKey, 0, len(vals))
+	for _, val := range vals {
+		nkc := NamedCertKey{}
+		if err := nkc.Set(val); err != nil {
+			return err
+		}
+		nkcs = append(nkcs, nkc)
+	}
+	*a.value = nkcs
+	return nil
+}
+
+// GetSlice implements github.com/spf13/pflag.SliceValue
+func (a *NamedCertKeyArray) GetSlice() []string {
+	nkcs := make([]string, 0, len(*a.value))
+	for i := range *a.value {
+		nkcs = append(nkcs, (*a.value)[i].String())
+	}
+	return nkcs
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -37,6 +40,10 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.
 {{end}}`
 )
 
+// SectionAnnotation is the flag annotation which records the section of a flag, the first
+// element is the section name and the second one is the index of the section, see SetUsageAndHelpFunc.
+const SectionAnnotation = "cliflag.section"
+
 // NamedFlagSets stores named flag sets in the order of calling FlagSet.
 type NamedFlagSets struct {
 	// Order is an ordered list of flag set names.
@@ -45,6 +52,9 @@ type NamedFlagSets struct {
 	FlagSets map[string]*pflag.FlagSet
 	// NormalizeNameFunc is the normalize function which used to initialize FlagSets created by NamedFlagSets.
 	NormalizeNameFunc func(f *pflag.FlagSet, name string) pflag.NormalizedName
+	// EnvPrefix is the prefix of the environment variables which set the flags, see SetFromEnv.
+	// If it is empty, the flags can't be set from environment variables.
+	EnvPrefix string
 }
 
 // FlagSet returns the flag set with the given name and adds it to the
@@ -66,35 +76,11 @@ func (nfs *NamedFlagSets) FlagSet(name string) *pflag.FlagSet {
 }
 
 // PrintSections prints the given names flag sets in sections, with the maximal given column number.
-// If cols is zero, lines are not wrapped.
+// If cols is zero, lines are not wrapped. If EnvPrefix is set, the environment variable
+// of each flag is printed after its usage. The flags of the section with an empty name
+// are printed as "Flags". The usages of each section are aligned separately, see UsageFormat.
 func PrintSections(w io.Writer, fss NamedFlagSets, cols int) {
-	for _, name := range fss.Order {
-		fs := fss.FlagSets[name]
-		if !fs.HasFlags() {
-			continue
-		}
-
-		wideFS := pflag.NewFlagSet("", pflag.ExitOnError)
-		wideFS.AddFlagSet(fs)
-
-		var zzz string
-		if cols > 24 {
-			zzz = strings.Repeat("z", cols-24)
-			wideFS.Int(zzz, 0, strings.Repeat("z", cols-24))
-		}
-
-		var buf bytes.Buffer
-		_, _ = fmt.Fprintf(&buf, "\n%s flags:\n%s", strings.ToUpper(name[:1])+name[1:], wideFS.FlagUsagesWrapped(cols))
-
-		if cols > 24 {
-			i := strings.Index(buf.String(), zzz)
-			lines := strings.Split(buf.String()[:i], "\n")
-			_, _ = fmt.Fprint(w, strings.Join(lines[:len(lines)-1], "\n"))
-			_, _ = fmt.Fprintln(w)
-		} else {
-			_, _ = fmt.Fprint(w, buf.String())
-		}
-	}
+	UsageFormat{Cols: cols}.PrintSections(w, fss)
 }
 
 // PrintAliases prints the aliases.
@@ -117,26 +103,125 @@ func PrintMore(w io.Writer, cmd *cobra.Command) {
 	_ = tmpl(w, moreFmt, cmd)
 }
 
+// HelpOption configures the command whose usage and help functions are set by SetUsageAndHelpFunc.
+type HelpOption func(cmd *cobra.Command)
+
+// WithOutput sets both the output and the error output of the command to w, so that the help,
+// the usage and the errors of the command and its subcommands are all written to w.
+func WithOutput(w io.Writer) HelpOption {
+	return func(cmd *cobra.Command) {
+		cmd.SetOut(w)
+		cmd.SetErr(w)
+	}
+}
+
 // SetUsageAndHelpFunc set both usage and help function.
-// Print the flag sets we need instead of all of them.
-func SetUsageAndHelpFunc(cmd *cobra.Command, fss NamedFlagSets, cols int) {
-	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
-		_, _ = fmt.Fprintf(cmd.OutOrStderr(), usageFmt, cmd.UseLine())
-		PrintAliases(cmd.OutOrStderr(), cmd)
-		PrintSubCommands(cmd.OutOrStderr(), cmd)
-		PrintSections(cmd.OutOrStderr(), fss, cols)
-		PrintExamples(cmd.OutOrStderr(), cmd)
-		PrintMore(cmd.OutOrStderr(), cmd)
+// Print the flag sets we need instead of all of them. The flags of fss are annotated with
+// their sections, see SectionAnnotation. The subcommands which inherit the functions print
+// their local flags instead of fss, in their annotated sections if they have any.
+// The persistent flags inherited from the parent commands are printed in their annotated
+// sections under "Inherited flags".
+//
+// The help, requested by --help or the help command, is written to the output of the command,
+// so that it can be piped, e.g. to less. The usage is written to the error output of the
+// command. Note that cobra captures the usage it prints on errors and prints it to the output
+// of the root command if it is set by SetOut, and to stderr otherwise.
+func SetUsageAndHelpFunc(cmd *cobra.Command, fss NamedFlagSets, cols int, opts ...HelpOption) {
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	for i, name := range fss.Order {
+		index := strconv.Itoa(i)
+		fss.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if flag.Annotations == nil {
+				flag.Annotations = map[string][]string{}
+			}
+			flag.Annotations[SectionAnnotation] = []string{name, index}
+		})
+	}
+
+	printSections := func(w io.Writer, c *cobra.Command) {
+		local := fss
+		if c != cmd {
+			local = sections(c.LocalFlags(), fss.EnvPrefix)
+		}
+		PrintSections(w, local, cols)
+		PrintInheritedSections(w, sections(c.InheritedFlags(), fss.EnvPrefix), cols)
+	}
+	printUsage := func(w io.Writer, c *cobra.Command) {
+		_, _ = fmt.Fprintf(w, usageFmt, c.UseLine())
+		PrintAliases(w, c)
+		PrintSubCommands(w, c)
+		printSections(w, c)
+		PrintExamples(w, c)
+		PrintMore(w, c)
+	}
+	cmd.SetUsageFunc(func(c *cobra.Command) error {
+		printUsage(c.ErrOrStderr(), c)
 		return nil
 	})
-	cmd.SetHelpFunc(func(cmd *cobra.Command, _ []string) {
-		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s\n\n"+usageFmt, cmd.Long, cmd.UseLine())
-		PrintAliases(cmd.OutOrStderr(), cmd)
-		PrintSubCommands(cmd.OutOrStderr(), cmd)
-		PrintSections(cmd.OutOrStderr(), fss, cols)
-		PrintExamples(cmd.OutOrStderr(), cmd)
-		PrintMore(cmd.OutOrStderr(), cmd)
+	cmd.SetHelpFunc(func(c *cobra.Command, _ []string) {
+		if len(c.Long) > 0 {
+			_, _ = fmt.Fprintf(c.OutOrStdout(), "%s\n\n", c.Long)
+		}
+		printUsage(c.OutOrStdout(), c)
+	})
+}
+
+// PrintInheritedSections prints the given named flag sets under "Inherited flags", indented
+// by two spaces, with the maximal given column number. Nothing is printed if there is no flag.
+func PrintInheritedSections(w io.Writer, fss NamedFlagSets, cols int) {
+	var buf bytes.Buffer
+	if cols > 2 {
+		cols -= 2
+	}
+	PrintSections(&buf, fss, cols)
+	if buf.Len() == 0 {
+		return
+	}
+	_, _ = fmt.Fprint(w, "\nInherited flags:\n")
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			line = "  " + line
+		}
+		_, _ = fmt.Fprint(w, line)
+	}
+}
+
+// sections returns the flags of fs in the sections of their SectionAnnotation, ordered by
+// the indexes of the sections. The flags without the annotation are in the last section,
+// whose name is empty.
+func sections(fs *pflag.FlagSet, envPrefix string) NamedFlagSets {
+	type section struct {
+		name  string
+		index int
+	}
+	var order []section
+	grouped := map[string]*pflag.FlagSet{}
+	fs.VisitAll(func(flag *pflag.Flag) {
+		s := section{index: math.MaxInt}
+		if annotation := flag.Annotations[SectionAnnotation]; len(annotation) > 1 {
+			s.name = annotation[0]
+			s.index, _ = strconv.Atoi(annotation[1])
+		}
+		if _, ok := grouped[s.name]; !ok {
+			grouped[s.name] = pflag.NewFlagSet(s.name, pflag.ExitOnError)
+			order = append(order, s)
+		}
+		grouped[s.name].AddFlag(flag)
+	})
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].index != order[j].index {
+			return order[i].index < order[j].index
+		}
+		return order[i].name < order[j].name
 	})
+
+	nfs := NamedFlagSets{FlagSets: grouped, EnvPrefix: envPrefix}
+	for _, s := range order {
+		nfs.Order = append(nfs.Order, s.name)
+	}
+	return nfs
 }
 
 // tmpl executes the given template text on data, writing the result to w.
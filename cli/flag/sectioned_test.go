@@ -0,0 +1,218 @@
+package flag
+
+import (
+	"bytes"
+	goflag "flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newSectionedCommands() (root, server, status *cobra.Command) {
+	run := func(*cobra.Command, []string) {}
+	root = &cobra.Command{Use: "app", Run: run}
+	server = &cobra.Command{Use: "server", Run: run}
+	status = &cobra.Command{Use: "status", Run: run}
+	root.AddCommand(server, status)
+
+	var rootFss NamedFlagSets
+	global := rootFss.FlagSet("global")
+	global.String("log-level", "info", "The log level.")
+	rootFss.FlagSet("generic").Bool("debug", false, "Enable the debug mode.")
+	root.PersistentFlags().AddFlagSet(global)
+	root.Flags().AddFlagSet(rootFss.FlagSet("generic"))
+	SetUsageAndHelpFunc(root, rootFss, 0)
+
+	var serverFss NamedFlagSets
+	serving := serverFss.FlagSet("secure serving")
+	serving.Int("secure-port", 443, "The port on which to serve HTTPS.")
+	server.Flags().AddFlagSet(serving)
+	SetUsageAndHelpFunc(server, serverFss, 0)
+
+	status.Flags().Bool("watch", false, "Watch the status.")
+	return root, server, status
+}
+
+func TestSetUsageAndHelpFunc(t *testing.T) {
+	cases := []struct {
+		args      []string
+		expect    []string
+		notExpect []string
+	}{
+		{
+			args:      []string{"--help"},
+			expect:    []string{"Global flags:\n      --log-level", "Generic flags:\n      --debug"},
+			notExpect: []string{"Inherited flags:", "secure-port"},
+		},
+		{
+			args: []string{"server", "--help"},
+			expect: []string{
+				"Secure serving flags:\n      --secure-port",
+				"Inherited flags:\n\n  Global flags:\n        --log-level",
+			},
+			notExpect: []string{"--debug", "watch"},
+		},
+		{
+			args: []string{"status", "--help"},
+			expect: []string{
+				"\nFlags:\n  -h, --help",
+				"      --watch",
+				"Inherited flags:\n\n  Global flags:\n        --log-level",
+			},
+			notExpect: []string{"--debug", "secure-port", "Generic flags:"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			root, _, _ := newSectionedCommands()
+			var buf bytes.Buffer
+			root.SetOut(&buf)
+			root.SetErr(&buf)
+			root.SetArgs(c.args)
+			if err := root.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			for _, expect := range c.expect {
+				if !strings.Contains(got, expect) {
+					t.Errorf("expect %q in the help:\n%s", expect, got)
+				}
+			}
+			for _, notExpect := range c.notExpect {
+				if strings.Contains(got, notExpect) {
+					t.Errorf("unexpected %q in the help:\n%s", notExpect, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSectionsOrder(t *testing.T) {
+	var fss NamedFlagSets
+	fss.FlagSet("zeta").Bool("a", false, "")
+	fss.FlagSet("alpha").Bool("b", false, "")
+	SetUsageAndHelpFunc(&cobra.Command{Use: "app"}, fss, 0)
+
+	cmd := &cobra.Command{Use: "sub"}
+	cmd.Flags().AddFlagSet(fss.FlagSet("alpha"))
+	cmd.Flags().AddFlagSet(fss.FlagSet("zeta"))
+	cmd.Flags().Bool("c", false, "")
+
+	got := sections(cmd.Flags(), "")
+	if expect := []string{"zeta", "alpha", ""}; strings.Join(got.Order, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect sections %q but got %q", expect, got.Order)
+	}
+}
+
+var update = goflag.Bool("update", false, "update the golden files in testdata")
+
+func TestHelpOutputGolden(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		// output is the writer of WithOutput, if it is not nil
+		output *bytes.Buffer
+		// noLong clears the long description of the root command
+		noLong bool
+	}{
+		{name: "help", args: []string{"--help"}},
+		{name: "help-no-long", args: []string{"--help"}, noLong: true},
+		{name: "help-subcommand", args: []string{"help", "server"}},
+		{name: "usage-error", args: []string{"server", "--unknown"}},
+		{name: "with-output", args: []string{"server", "--unknown"}, output: &bytes.Buffer{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := newGoldenCommands(c.output)
+			if c.noLong {
+				root.Long = ""
+			}
+			root.SetArgs(c.args)
+			stdout, stderr := captureOutput(t, func() { _ = root.Execute() })
+			assertGolden(t, c.name+".stdout", stdout)
+			assertGolden(t, c.name+".stderr", stderr)
+			if c.output != nil {
+				assertGolden(t, c.name+".output", c.output.String())
+			}
+		})
+	}
+}
+
+func newGoldenCommands(output *bytes.Buffer) *cobra.Command {
+	root := &cobra.Command{Use: "demo", Long: "Demo runs the demo components."}
+	server := &cobra.Command{
+		Use:     "server",
+		Short:   "Run the demo server.",
+		Long:    "Server runs the demo server.",
+		Example: "  demo server --secure-port 8443",
+		Run:     func(*cobra.Command, []string) {},
+	}
+	root.AddCommand(server)
+
+	var rootFss NamedFlagSets
+	global := rootFss.FlagSet("global")
+	global.String("log-level", "info", "The log level, one of debug, info, warn and error.")
+	root.PersistentFlags().AddFlagSet(global)
+	var opts []HelpOption
+	if output != nil {
+		opts = append(opts, WithOutput(output))
+	}
+	SetUsageAndHelpFunc(root, rootFss, 80, opts...)
+
+	var serverFss NamedFlagSets
+	serving := serverFss.FlagSet("secure serving")
+	serving.Int("secure-port", 443, "The port on which to serve HTTPS with authentication and authorization.")
+	serving.StringSlice("tls-cipher-suites", nil, "Comma-separated list of cipher suites for the server.")
+	server.Flags().AddFlagSet(serving)
+	SetUsageAndHelpFunc(server, serverFss, 80)
+	return root
+}
+
+// captureOutput returns what f writes to the standard output and the standard error.
+func captureOutput(t *testing.T, f func()) (stdout, stderr string) {
+	dir := t.TempDir()
+	outFile, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outFile.Close()
+	errFile, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errFile.Close()
+
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() {
+		os.Stdout, os.Stderr = oldOut, oldErr
+	}()
+	f()
+
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	return string(out), string(errOut)
+}
+
+// assertGolden compares got with the golden file testdata/<name>.golden,
+// which is updated instead if the tests run with -update.
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expect, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(expect) {
+		t.Errorf("%s: expect:\n%s\nbut got:\n%s", path, expect, got)
+	}
+}
@@ -21,6 +21,7 @@ func NewStringSlice(s *[]string) *StringSlice {
 
 var _ goflag.Value = &StringSlice{}
 var _ pflag.Value = &StringSlice{}
+var _ pflag.SliceValue = &StringSlice{}
 
 func (s *StringSlice) String() string {
 	if s == nil || s.value == nil {
@@ -43,4 +44,30 @@ func (s *StringSlice) Set(val string) error {
 
 func (StringSlice) Type() string {
 	return "sliceString"
-}
\ No newline at end of file
+}
+
+// Append implements github.com/spf13/pflag.SliceValue
+func (s *StringSlice) Append(val string) error {
+	if s.value == nil {
+		return fmt.Errorf("no target (nil pointer to []string)")
+	}
+	*s.value = append(*s.value, val)
+	return nil
+}
+
+// Replace implements github.com/spf13/pflag.SliceValue
+func (s *StringSlice) Replace(vals []string) error {
+	if s.value == nil {
+		return fmt.Errorf("no target (nil pointer to []string)")
+	}
+	*s.value = append(make([]string, 0, len(vals)), vals...)
+	return nil
+}
+
+// GetSlice implements github.com/spf13/pflag.SliceValue
+func (s *StringSlice) GetSlice() []string {
+	if s == nil || s.value == nil {
+		return nil
+	}
+	return append([]string{}, *s.value...)
+}
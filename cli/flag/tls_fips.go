@@ -0,0 +1,145 @@
+package flag
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/spf13/pflag"
+
+	"github.com/shipengqi/component-base/util/sets"
+)
+
+const tlsFIPSModeFlagName = "tls-fips-mode"
+
+var (
+	tlsFIPSMode atomic.Bool
+
+	// fipsCiphers are the FIPS 140 approved cipher suites: AES-GCM with ECDHE, and the TLS 1.3 AES-GCM suites.
+	fipsCiphers = sets.NewString(
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_AES_128_GCM_SHA256",
+		"TLS_AES_256_GCM_SHA384",
+	)
+	// fipsVersions are the FIPS 140 approved TLS versions.
+	fipsVersions = sets.NewString("VersionTLS12", "VersionTLS13")
+	// fipsCurves are the FIPS 140 approved curves.
+	fipsCurves = sets.NewString("P-256", "P-384")
+)
+
+// SetTLSFIPSMode turns the FIPS mode on or off. In FIPS mode, TLSCipherSuites, TLSVersion
+// and TLSCurves reject the values which are not FIPS 140 approved, and the functions listing
+// the possible values only return the approved ones.
+// The cipher suites of TLS 1.3 are not configurable in Go, so the TLS version is limited to
+// TLS 1.2 unless the Go runtime itself runs in FIPS mode, see TLSFIPSMaxVersion.
+// The mode should be set before any flags are registered, so the help text lists the approved values only.
+func SetTLSFIPSMode(enabled bool) {
+	tlsFIPSMode.Store(enabled)
+}
+
+// TLSFIPSMode returns true if the FIPS mode is on.
+func TLSFIPSMode() bool {
+	return tlsFIPSMode.Load()
+}
+
+// TLSFIPSMaxVersion returns the maximum TLS version in FIPS mode, or zero if it is not limited.
+// TLS 1.3 is only allowed if the Go runtime runs in FIPS mode as well, e.g. GODEBUG=fips140=on,
+// which restricts the TLS 1.3 cipher suites to the approved ones, otherwise
+// TLS_CHACHA20_POLY1305_SHA256 could be negotiated.
+func TLSFIPSMaxVersion() uint16 {
+	if !TLSFIPSMode() || runtimeFIPSMode() {
+		return 0
+	}
+	return tls.VersionTLS12
+}
+
+// AddTLSFIPSModeFlag adds a flag for turning the FIPS mode on to the specified FlagSet.
+// The usages of the other flags are built before the flag is parsed, so they still list all
+// the possible values, call SetTLSFIPSMode before adding the flags to list the approved ones.
+// The cipher suites table of TLSCipherHelpValue is only restricted if the flag precedes it.
+func AddTLSFIPSModeFlag(fs *pflag.FlagSet) {
+	fs.Var(tlsFIPSModeValue{}, tlsFIPSModeFlagName, ""+
+		"Restrict the TLS cipher suites, versions and curves to the FIPS 140 approved ones: "+
+		strings.Join(fipsCiphers.List(), ", ")+"; "+
+		strings.Join(fipsVersions.List(), ", ")+"; "+
+		strings.Join(fipsCurves.List(), ", ")+". "+
+		"TLS 1.3 is only allowed if the Go runtime runs in FIPS mode as well, e.g. GODEBUG=fips140=on. "+
+		"The possible values listed in the help of the other flags are not restricted by this flag, "+
+		"and it must precede --tls-cipher-suites=help to restrict the cipher suites table.")
+	// "--tls-fips-mode" will be treated as "--tls-fips-mode=true"
+	fs.Lookup(tlsFIPSModeFlagName).NoOptDefVal = "true"
+}
+
+// tlsFIPSModeValue implements pflag.Value for the FIPS mode switch.
+type tlsFIPSModeValue struct{}
+
+func (tlsFIPSModeValue) String() string {
+	return strconv.FormatBool(TLSFIPSMode())
+}
+
+func (tlsFIPSModeValue) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetTLSFIPSMode(enabled)
+	return nil
+}
+
+func (tlsFIPSModeValue) Type() string {
+	return "bool"
+}
+
+// IsBoolFlag implements the goflag boolFlag interface.
+func (tlsFIPSModeValue) IsBoolFlag() bool {
+	return true
+}
+
+// fipsAllowed filters the names by the allowed set if the FIPS mode is on.
+func fipsAllowed(names []string, allowed sets.String) []string {
+	if !TLSFIPSMode() {
+		return names
+	}
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		if allowed.Has(name) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// fipsRejected returns an error naming the values which are not allowed if the FIPS mode is on.
+func fipsRejected(kind string, names []string, allowed sets.String) error {
+	if !TLSFIPSMode() {
+		return nil
+	}
+	rejected := sets.NewString()
+	for _, name := range names {
+		if !allowed.Has(name) {
+			rejected.Insert(name)
+		}
+	}
+	if rejected.Len() == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s not allowed in FIPS mode: %s", kind, strings.Join(rejected.List(), ", "))
+}
+
+// fipsProfile restricts the profile to the FIPS 140 approved values if the FIPS mode is on.
+func fipsProfile(profile TLSSecurityProfile) TLSSecurityProfile {
+	if !TLSFIPSMode() {
+		return profile
+	}
+	profile.CipherSuites = fipsAllowed(profile.CipherSuites, fipsCiphers)
+	profile.CurvePreferences = fipsAllowed(profile.CurvePreferences, fipsCurves)
+	if !fipsVersions.Has(profile.MinVersion) {
+		profile.MinVersion = "VersionTLS12"
+	}
+	return profile
+}
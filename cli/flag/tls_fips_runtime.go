@@ -0,0 +1,10 @@
+//go:build go1.24
+
+package flag
+
+import "crypto/fips140"
+
+// runtimeFIPSMode returns true if the Go runtime runs in FIPS 140-3 mode, e.g. GODEBUG=fips140=on.
+func runtimeFIPSMode() bool {
+	return fips140.Enabled()
+}
@@ -0,0 +1,8 @@
+//go:build !go1.24
+
+package flag
+
+// runtimeFIPSMode returns false, the Go runtime has no FIPS 140-3 mode before Go 1.24.
+func runtimeFIPSMode() bool {
+	return false
+}
@@ -0,0 +1,112 @@
+package flag
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestTLSFIPSMode(t *testing.T) {
+	SetTLSFIPSMode(true)
+	defer SetTLSFIPSMode(false)
+
+	expectedCiphers := []string{
+		"TLS_AES_128_GCM_SHA256",
+		"TLS_AES_256_GCM_SHA384",
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	}
+	if values := TLSCipherPossibleValues(); !reflect.DeepEqual(values, expectedCiphers) {
+		t.Errorf("expected cipher suites %v, got %v", expectedCiphers, values)
+	}
+	if values := InsecureTLSCipherNames(); len(values) != 0 {
+		t.Errorf("expected no insecure cipher suites, got %v", values)
+	}
+	if values := TLSPossibleVersions(); !reflect.DeepEqual(values, []string{"VersionTLS12", "VersionTLS13"}) {
+		t.Errorf("unexpected versions %v", values)
+	}
+	if values := TLSPossibleCurves(); !reflect.DeepEqual(values, []string{"P-256", "P-384"}) {
+		t.Errorf("unexpected curves %v", values)
+	}
+
+	_, err := TLSCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA", "TLS_CHACHA20_POLY1305_SHA256"})
+	if err == nil || !strings.Contains(err.Error(), "TLS_CHACHA20_POLY1305_SHA256, TLS_RSA_WITH_RC4_128_SHA") {
+		t.Errorf("expected an error naming the rejected cipher suites, got %v", err)
+	}
+	if _, err = TLSCipherSuites(expectedCiphers); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = TLSVersion("VersionTLS11"); err == nil {
+		t.Errorf("expected an error for VersionTLS11")
+	}
+	if _, err = TLSVersion("VersionTLS12"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = TLSCurves([]string{"X25519"}); err == nil {
+		t.Errorf("expected an error for X25519")
+	}
+
+	profile, _ := TLSProfile(TLSProfileOld)
+	if profile.MinVersion != "VersionTLS12" {
+		t.Errorf("expected the profile min version to be raised to VersionTLS12, got %s", profile.MinVersion)
+	}
+	if _, err = TLSCipherSuites(profile.CipherSuites); err != nil {
+		t.Errorf("expected the profile to be restricted to the approved cipher suites, got %v", err)
+	}
+	if _, err = TLSCurves(profile.CurvePreferences); err != nil {
+		t.Errorf("expected the profile to be restricted to the approved curves, got %v", err)
+	}
+}
+
+func TestTLSFIPSModeFlag(t *testing.T) {
+	defer SetTLSFIPSMode(false)
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddTLSFIPSModeFlag(fs)
+	if err := fs.Parse([]string{"--tls-fips-mode"}); err != nil {
+		t.Fatal(err)
+	}
+	if !TLSFIPSMode() {
+		t.Errorf("expected the FIPS mode to be on")
+	}
+	if _, err := TLSVersion("VersionTLS10"); err == nil {
+		t.Errorf("expected an error for VersionTLS10")
+	}
+}
+
+func TestTLSFIPSModeFlagUsage(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddTLSFIPSModeFlag(fs)
+	if usage := fs.Lookup("tls-fips-mode").Usage; !strings.Contains(usage, "TLS 1.3 is only allowed if the Go runtime runs in FIPS mode") {
+		t.Errorf("expected the usage to describe the TLS 1.3 limitation, got %q", usage)
+	}
+}
+
+func TestTLSFIPSMaxVersion(t *testing.T) {
+	if TLSFIPSMaxVersion() != 0 {
+		t.Errorf("expected no maximum version without the FIPS mode")
+	}
+	SetTLSFIPSMode(true)
+	defer SetTLSFIPSMode(false)
+	expected := uint16(tls.VersionTLS12)
+	if runtimeFIPSMode() {
+		expected = 0
+	}
+	if version := TLSFIPSMaxVersion(); version != expected {
+		t.Errorf("expected the maximum version %x, got %x", expected, version)
+	}
+}
+
+func TestTLSFIPSModeFlagUsageHelp(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddTLSFIPSModeFlag(fs)
+	if usage := fs.Lookup("tls-fips-mode").Usage; !strings.Contains(usage, "not restricted by this flag") ||
+		!strings.Contains(usage, "must precede --tls-cipher-suites=help") {
+		t.Errorf("expected the usage to describe the help output, got %q", usage)
+	}
+}
@@ -0,0 +1,146 @@
+package flag
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/shipengqi/component-base/util/sets"
+)
+
+const (
+	// TLSProfileModern is for services with clients that support TLS 1.3 and don't need backward compatibility.
+	TLSProfileModern = "modern"
+	// TLSProfileIntermediate is the recommended configuration for a general-purpose server.
+	TLSProfileIntermediate = "intermediate"
+	// TLSProfileOld is for services accessed by very old clients or libraries.
+	TLSProfileOld = "old"
+)
+
+// TLSSecurityProfile is a named set of TLS settings modelled on the Mozilla
+// server side TLS guidelines (https://wiki.mozilla.org/Security/Server_Side_TLS).
+type TLSSecurityProfile struct {
+	// Name is the name of the profile.
+	Name string
+	// MinVersion is the minimum TLS version, one of TLSPossibleVersions().
+	MinVersion string
+	// CipherSuites is the ordered list of cipher suite names, one of TLSCipherPossibleValues().
+	CipherSuites []string
+	// CurvePreferences is the ordered list of elliptic curve names, one of TLSPossibleCurves().
+	CurvePreferences []string
+}
+
+// String returns a description of the profile contents.
+func (p TLSSecurityProfile) String() string {
+	return fmt.Sprintf("%s: min version %s, cipher suites %s, curves %s",
+		p.Name, p.MinVersion, strings.Join(p.CipherSuites, ", "), strings.Join(p.CurvePreferences, ", "))
+}
+
+var (
+	tls13CipherNames = []string{
+		"TLS_AES_128_GCM_SHA256",
+		"TLS_AES_256_GCM_SHA384",
+		"TLS_CHACHA20_POLY1305_SHA256",
+	}
+	intermediateCipherNames = append(append([]string{}, tls13CipherNames...),
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	)
+	oldCipherNames = append(append([]string{}, intermediateCipherNames...),
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+		"TLS_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_RSA_WITH_AES_128_CBC_SHA256",
+		"TLS_RSA_WITH_AES_128_CBC_SHA",
+		"TLS_RSA_WITH_AES_256_CBC_SHA",
+		"TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	)
+
+	// profiles maps profile names into the profile contents.
+	profiles = map[string]TLSSecurityProfile{
+		TLSProfileModern: {
+			Name:             TLSProfileModern,
+			MinVersion:       "VersionTLS13",
+			CipherSuites:     tls13CipherNames,
+			CurvePreferences: []string{"X25519", "P-256", "P-384"},
+		},
+		TLSProfileIntermediate: {
+			Name:             TLSProfileIntermediate,
+			MinVersion:       "VersionTLS12",
+			CipherSuites:     intermediateCipherNames,
+			CurvePreferences: []string{"X25519", "P-256", "P-384"},
+		},
+		TLSProfileOld: {
+			Name:             TLSProfileOld,
+			MinVersion:       "VersionTLS10",
+			CipherSuites:     oldCipherNames,
+			CurvePreferences: []string{"X25519", "P-256", "P-384"},
+		},
+	}
+
+	// curves maps strings into tls package curve constants.
+	curves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P-256":  tls.CurveP256,
+		"P-384":  tls.CurveP384,
+		"P-521":  tls.CurveP521,
+	}
+)
+
+// TLSProfileNames returns the names of all the TLS security profiles,
+// ordered from the most to the least restrictive.
+func TLSProfileNames() []string {
+	return []string{TLSProfileModern, TLSProfileIntermediate, TLSProfileOld}
+}
+
+// TLSProfile returns the TLS security profile with the given name. In FIPS mode,
+// the profile is restricted to the approved values and a min version of at least TLS 1.2.
+func TLSProfile(name string) (TLSSecurityProfile, error) {
+	profile, ok := profiles[name]
+	if !ok {
+		return TLSSecurityProfile{}, fmt.Errorf("unknown tls profile %q", name)
+	}
+	// copy the lists, so the callers can't change the profile
+	profile.CipherSuites = append([]string{}, profile.CipherSuites...)
+	profile.CurvePreferences = append([]string{}, profile.CurvePreferences...)
+	return fipsProfile(profile), nil
+}
+
+// TLSPossibleCurves returns all acceptable values for TLS curves.
+// In FIPS mode, only the approved curves are returned.
+func TLSPossibleCurves() []string {
+	curveKeys := sets.NewString()
+	for key := range curves {
+		curveKeys.Insert(key)
+	}
+	return fipsAllowed(curveKeys.List(), fipsCurves)
+}
+
+// TLSCurves returns a list of curve IDs from the curve names passed.
+// In FIPS mode, the curves which are not approved are rejected.
+func TLSCurves(curveNames []string) ([]tls.CurveID, error) {
+	if len(curveNames) == 0 {
+		return nil, nil
+	}
+	curveIDs := make([]tls.CurveID, 0, len(curveNames))
+	for _, name := range curveNames {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("curve %s not supported or doesn't exist", name)
+		}
+		curveIDs = append(curveIDs, id)
+	}
+	if err := fipsRejected("curves", curveNames, fipsCurves); err != nil {
+		return nil, err
+	}
+	return curveIDs, nil
+}
@@ -0,0 +1,59 @@
+package flag
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestTLSProfile(t *testing.T) {
+	for _, name := range TLSProfileNames() {
+		profile, err := TLSProfile(name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if profile.Name != name {
+			t.Errorf("%s: expected name %q, got %q", name, name, profile.Name)
+		}
+		if _, err = TLSVersion(profile.MinVersion); err != nil {
+			t.Errorf("%s: invalid min version: %v", name, err)
+		}
+		if _, err = TLSCipherSuites(profile.CipherSuites); err != nil {
+			t.Errorf("%s: invalid cipher suites: %v", name, err)
+		}
+		if _, err = TLSCurves(profile.CurvePreferences); err != nil {
+			t.Errorf("%s: invalid curves: %v", name, err)
+		}
+
+		// the returned profile is a copy
+		profile.CipherSuites[0] = "foo"
+		if again, _ := TLSProfile(name); again.CipherSuites[0] == "foo" {
+			t.Errorf("%s: expected the profile to be immutable", name)
+		}
+	}
+
+	if _, err := TLSProfile("foo"); err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+}
+
+func TestTLSCurves(t *testing.T) {
+	tests := []struct {
+		names         []string
+		expected      []tls.CurveID
+		expectedError bool
+	}{
+		{names: nil, expected: nil},
+		{names: []string{"X25519", "P-256"}, expected: []tls.CurveID{tls.X25519, tls.CurveP256}},
+		{names: []string{"foo"}, expected: nil, expectedError: true},
+	}
+	for i, test := range tests {
+		curves, err := TLSCurves(test.names)
+		if !reflect.DeepEqual(curves, test.expected) {
+			t.Errorf("%d: expected %+v, got %+v", i, test.expected, curves)
+		}
+		if test.expectedError != (err != nil) {
+			t.Errorf("%d: expected error %v, got %v", i, test.expectedError, err)
+		}
+	}
+}
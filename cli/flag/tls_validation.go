@@ -0,0 +1,142 @@
+package flag
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// http2RequiredCiphers are the cipher suites of which at least one is required
+// by HTTP/2 under TLS 1.2 (https://httpwg.org/specs/rfc7540.html#rfc.section.9.2.2).
+var http2RequiredCiphers = []string{
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+}
+
+// ValidateTLSCipherSuites checks the combination of the min TLS version, the max TLS version
+// and the cipher suite names. An empty min version is the DefaultTLSVersion(), an empty max
+// version is TLS 1.3 and an empty cipher suite list means the Go defaults.
+// It returns errors for setups which can't work, e.g. no usable cipher suite for any of the
+// enabled TLS versions, or none of the cipher suites required by HTTP/2 under TLS 1.2 if http2
+// is true. It returns warnings for cipher suites which Go will ignore.
+func ValidateTLSCipherSuites(minVersionName, maxVersionName string, cipherNames []string, http2 bool) ([]string, []error) {
+	return validateTLSCipherSuites(minVersionName, maxVersionName, cipherNames, http2, false)
+}
+
+// ValidateTLSProfileCipherSuites is like ValidateTLSCipherSuites for the cipher suites of a
+// TLSSecurityProfile, which list the cipher suites of all TLS versions of the profile, so the
+// cipher suites which Go ignores are not warned about, only the enabled TLS versions without
+// any usable cipher suite are.
+func ValidateTLSProfileCipherSuites(minVersionName, maxVersionName string, cipherNames []string, http2 bool) ([]string, []error) {
+	return validateTLSCipherSuites(minVersionName, maxVersionName, cipherNames, http2, true)
+}
+
+func validateTLSCipherSuites(minVersionName, maxVersionName string, cipherNames []string, http2, profile bool) ([]string, []error) {
+	var (
+		warnings []string
+		errs     []error
+	)
+
+	minVersion, err := TLSVersion(minVersionName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	maxVersion := uint16(tls.VersionTLS13)
+	if len(maxVersionName) > 0 {
+		if maxVersion, err = TLSVersion(maxVersionName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if minVersion > maxVersion {
+		return nil, []error{fmt.Errorf("min tls version %s is greater than max tls version %s",
+			tlsVersionName(minVersion), tlsVersionName(maxVersion))}
+	}
+	if len(cipherNames) == 0 {
+		return nil, nil
+	}
+
+	if _, err = TLSCipherSuites(cipherNames); err != nil {
+		return nil, []error{err}
+	}
+
+	// TLS 1.3 cipher suites are not configurable, every TLS 1.3 connection can be established.
+	tls13Enabled := maxVersion >= tls.VersionTLS13
+	// the TLS versions below 1.3 which are enabled, but have no usable cipher suite
+	unusable := map[uint16]bool{}
+	for v := minVersion; v <= maxVersion && v < tls.VersionTLS13; v++ {
+		unusable[v] = true
+	}
+	http2Usable := false
+
+	for _, name := range cipherNames {
+		info, _ := TLSCipherInfoByName(name)
+		if supportsVersion(info, tls.VersionTLS13) {
+			if profile {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("cipher suite %s is a TLS 1.3 cipher suite, "+
+				"TLS 1.3 cipher suites are not configurable and it will be ignored", name))
+			continue
+		}
+
+		usable := false
+		for v := range unusable {
+			if supportsVersion(info, v) {
+				unusable[v] = false
+				usable = true
+			}
+		}
+		if !usable {
+			if profile {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("cipher suite %s only supports %s, which is not enabled by the "+
+				"tls versions %s-%s, it will be ignored", name, strings.Join(info.SupportedVersions, ","),
+				tlsVersionName(minVersion), tlsVersionName(maxVersion)))
+			continue
+		}
+		for _, required := range http2RequiredCiphers {
+			if info.Name == required {
+				http2Usable = true
+			}
+		}
+	}
+
+	for _, v := range sortedVersions(unusable) {
+		if tls13Enabled {
+			warnings = append(warnings, fmt.Sprintf("no usable cipher suite for %s, "+
+				"only %s connections can be established", tlsVersionName(v), tlsVersionName(tls.VersionTLS13)))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("no usable cipher suite for %s", tlsVersionName(v)))
+	}
+	if http2 && minVersion <= tls.VersionTLS12 && maxVersion >= tls.VersionTLS12 && !http2Usable {
+		errs = append(errs, fmt.Errorf("no usable cipher suite for HTTP/2 under %s, need at least one of %s",
+			tlsVersionName(tls.VersionTLS12), strings.Join(http2RequiredCiphers, ", ")))
+	}
+	return warnings, errs
+}
+
+func supportsVersion(info TLSCipherInfo, version uint16) bool {
+	name := tlsVersionName(version)
+	for _, v := range info.SupportedVersions {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedVersions returns the versions which are true in ascending order.
+func sortedVersions(versions map[uint16]bool) []uint16 {
+	var sorted []uint16
+	for v := uint16(tls.VersionTLS10); v <= tls.VersionTLS13; v++ {
+		if versions[v] {
+			sorted = append(sorted, v)
+		}
+	}
+	return sorted
+}
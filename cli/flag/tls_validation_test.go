@@ -0,0 +1,119 @@
+package flag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTLSCipherSuites(t *testing.T) {
+	tests := []struct {
+		desc       string
+		minVersion string
+		maxVersion string
+		ciphers    []string
+		http2      bool
+		warnings   []string
+		errors     []string
+	}{
+		{
+			desc: "defaults",
+		},
+		{
+			desc:       "min version greater than max version",
+			minVersion: "VersionTLS13",
+			maxVersion: "VersionTLS12",
+			errors:     []string{"min tls version VersionTLS13 is greater than max tls version VersionTLS12"},
+		},
+		{
+			desc:    "unknown cipher suite",
+			ciphers: []string{"foo"},
+			errors:  []string{"cipher suite foo not supported or doesn't exist"},
+		},
+		{
+			desc:    "TLS 1.2 cipher suites with HTTP/2",
+			ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			http2:   true,
+		},
+		{
+			desc:       "TLS 1.2 cipher suites under TLS 1.3",
+			minVersion: "VersionTLS13",
+			ciphers:    []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			http2:      true,
+			warnings:   []string{"cipher suite TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 only supports VersionTLS12"},
+		},
+		{
+			desc:     "TLS 1.3 cipher suites",
+			ciphers:  []string{"TLS_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			warnings: []string{"cipher suite TLS_AES_128_GCM_SHA256 is a TLS 1.3 cipher suite"},
+		},
+		{
+			desc:       "only TLS 1.3 cipher suites under TLS 1.2",
+			maxVersion: "VersionTLS12",
+			ciphers:    []string{"TLS_AES_128_GCM_SHA256"},
+			warnings:   []string{"cipher suite TLS_AES_128_GCM_SHA256 is a TLS 1.3 cipher suite"},
+			errors:     []string{"no usable cipher suite for VersionTLS12"},
+		},
+		{
+			desc:     "only TLS 1.3 cipher suites with TLS 1.3 enabled",
+			ciphers:  []string{"TLS_AES_128_GCM_SHA256"},
+			warnings: []string{"cipher suite TLS_AES_128_GCM_SHA256 is a TLS 1.3 cipher suite", "no usable cipher suite for VersionTLS12"},
+		},
+		{
+			desc:    "no HTTP/2 cipher suite under TLS 1.2",
+			ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			http2:   true,
+			errors:  []string{"no usable cipher suite for HTTP/2 under VersionTLS12"},
+		},
+		{
+			desc:       "TLS 1.2 only cipher suites under TLS 1.0",
+			minVersion: "VersionTLS10",
+			maxVersion: "VersionTLS12",
+			ciphers:    []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			errors:     []string{"no usable cipher suite for VersionTLS10", "no usable cipher suite for VersionTLS11"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			warnings, errs := ValidateTLSCipherSuites(test.minVersion, test.maxVersion, test.ciphers, test.http2)
+			if len(warnings) != len(test.warnings) {
+				t.Fatalf("expected %d warnings, got %v", len(test.warnings), warnings)
+			}
+			for i, warning := range warnings {
+				if !strings.Contains(warning, test.warnings[i]) {
+					t.Errorf("expected warning containing %q, got %q", test.warnings[i], warning)
+				}
+			}
+			if len(errs) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), test.errors[i]) {
+					t.Errorf("expected error containing %q, got %q", test.errors[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTLSProfileCipherSuites(t *testing.T) {
+	for _, name := range []string{TLSProfileModern, TLSProfileIntermediate, TLSProfileOld} {
+		profile, err := TLSProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		warnings, errs := ValidateTLSProfileCipherSuites(profile.MinVersion, "", profile.CipherSuites, true)
+		if len(warnings) != 0 || len(errs) != 0 {
+			t.Errorf("%s: unexpected warnings %v and errors %v", name, warnings, errs)
+		}
+	}
+
+	profile, _ := TLSProfile(TLSProfileModern)
+	warnings, errs := ValidateTLSProfileCipherSuites("VersionTLS12", "", profile.CipherSuites, true)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no usable cipher suite for VersionTLS12") {
+		t.Errorf("expected a warning about TLS 1.2, got %v", warnings)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "no usable cipher suite for HTTP/2") {
+		t.Errorf("expected an HTTP/2 error, got %v", errs)
+	}
+}
@@ -0,0 +1,295 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// usageGap is the number of spaces between the flag names and the usages.
+	usageGap = 3
+	// minUsageWidth is the minimal width of the wrapped usages, the usages of the flags whose
+	// names are too long to leave it are printed on the next line.
+	minUsageWidth = 24
+	// minUsageColumn is the minimal column of the wrapped usages, the usages are not wrapped
+	// if the lines are too narrow to leave minUsageWidth after it.
+	minUsageColumn = 16
+)
+
+// runeWidths measures the display width of runes, it doesn't depend on the locale, so that
+// the usages are the same in all environments.
+var runeWidths = &runewidth.Condition{StrictEmojiNeutral: true}
+
+// UsageFormat formats the usages of flags in two columns, the names of the flags with their
+// types and the usages. The usages are aligned in the same column and wrapped with a hanging
+// indent. The display width of the text is measured in terminal cells, ANSI escape sequences
+// are zero-width and East Asian wide characters are two cells wide.
+type UsageFormat struct {
+	// Cols is the maximal display width of the lines, the usages are not wrapped if it is zero.
+	Cols int
+	// AlignSections aligns the usages of all sections printed by PrintSections in the same
+	// column, otherwise the usages of each section are aligned separately.
+	AlignSections bool
+}
+
+// flagUsage is a line of the usages of the flags.
+type flagUsage struct {
+	// names are the shorthand and the name of the flag, followed by the type.
+	names string
+	// usage is the usage of the flag, followed by the default value and the deprecation.
+	usage string
+}
+
+// FlagUsages returns the usages of the visible flags of fs, one flag per line.
+func (f UsageFormat) FlagUsages(fs *pflag.FlagSet) string {
+	usages := flagUsages(fs, nil)
+	var b strings.Builder
+	f.write(&b, usages, usageColumn(usages))
+	return b.String()
+}
+
+// PrintSections prints the flags of the named flag sets in sections. If EnvPrefix is set,
+// the environment variable of each flag is printed after its usage. The flags of the section
+// with an empty name are printed as "Flags".
+func (f UsageFormat) PrintSections(w io.Writer, fss NamedFlagSets) {
+	var envName func(string) string
+	if len(fss.EnvPrefix) > 0 {
+		envName = fss.EnvVarName
+	}
+	sections := make([][]flagUsage, len(fss.Order))
+	column := 0
+	for i, name := range fss.Order {
+		sections[i] = flagUsages(fss.FlagSets[name], envName)
+		if c := usageColumn(sections[i]); c > column {
+			column = c
+		}
+	}
+
+	var b strings.Builder
+	for i, name := range fss.Order {
+		if len(sections[i]) == 0 {
+			continue
+		}
+		title := "Flags"
+		if len(name) > 0 {
+			title = strings.ToUpper(name[:1]) + name[1:] + " flags"
+		}
+		b.WriteString("\n" + title + ":\n")
+		if f.AlignSections {
+			f.write(&b, sections[i], column)
+		} else {
+			f.write(&b, sections[i], usageColumn(sections[i]))
+		}
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+// write writes the usages with the usages in the given column. If the lines are too narrow
+// for the column, the column is moved left and the usages of the wider names are printed
+// on the next line.
+func (f UsageFormat) write(b *strings.Builder, usages []flagUsage, column int) {
+	width := 0
+	if f.Cols > 0 {
+		wrapColumn := column
+		if f.Cols-wrapColumn < minUsageWidth {
+			wrapColumn = f.Cols - minUsageWidth
+		}
+		if wrapColumn >= minUsageColumn {
+			column, width = wrapColumn, f.Cols-wrapColumn
+		}
+	}
+	indent := strings.Repeat(" ", column)
+	for _, u := range usages {
+		b.WriteString(u.names)
+		if len(u.usage) == 0 {
+			b.WriteString("\n")
+			continue
+		}
+		if pad := column - displayWidth(u.names); pad >= usageGap {
+			b.WriteString(strings.Repeat(" ", pad))
+		} else {
+			b.WriteString("\n" + indent)
+		}
+		for i, line := range wrapText(u.usage, width) {
+			if i > 0 && len(line) > 0 {
+				b.WriteString(indent)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+}
+
+// flagUsages returns the usages of the visible flags of fs in lexicographical order,
+// envName returns the environment variable of a flag if it is not nil.
+func flagUsages(fs *pflag.FlagSet, envName func(string) string) []flagUsage {
+	var usages []flagUsage
+	fs.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden {
+			return
+		}
+		names := "      --" + flag.Name
+		if len(flag.Shorthand) > 0 && len(flag.ShorthandDeprecated) == 0 {
+			names = fmt.Sprintf("  -%s, --%s", flag.Shorthand, flag.Name)
+		}
+		varname, usage := pflag.UnquoteUsage(flag)
+		if len(varname) > 0 {
+			names += " " + varname
+		}
+		names += noOptDefault(flag)
+
+		if envName != nil {
+			usage += fmt.Sprintf(" [env: %s]", envName(flag.Name))
+		}
+		if !zeroDefault(flag) {
+			usage += fmt.Sprintf(" (default %s)", quoteDefault(flag))
+		}
+		if len(flag.Deprecated) > 0 {
+			usage += fmt.Sprintf(" (DEPRECATED: %s)", flag.Deprecated)
+		}
+		usages = append(usages, flagUsage{names: names, usage: strings.TrimSpace(usage)})
+	})
+	return usages
+}
+
+// usageColumn returns the column of the usages after the widest names of the usages.
+func usageColumn(usages []flagUsage) int {
+	column := 0
+	for _, u := range usages {
+		if w := displayWidth(u.names); w > column {
+			column = w
+		}
+	}
+	return column + usageGap
+}
+
+// noOptDefault returns the value of the flag if it is present without a value, e.g. `[="auto"]`,
+// it is empty for the bool flags whose value is true, and for the count flags which are incremented.
+func noOptDefault(flag *pflag.Flag) string {
+	if len(flag.NoOptDefVal) == 0 {
+		return ""
+	}
+	switch flag.Value.Type() {
+	case "string":
+		return fmt.Sprintf("[=%q]", flag.NoOptDefVal)
+	case "bool", "boolfunc":
+		if flag.NoOptDefVal == "true" {
+			return ""
+		}
+	case "count":
+		if flag.NoOptDefVal == "+1" {
+			return ""
+		}
+	}
+	return "[=" + flag.NoOptDefVal + "]"
+}
+
+// zeroDefault returns true if the default value of the flag is the zero value of its type,
+// which is not printed.
+func zeroDefault(flag *pflag.Flag) bool {
+	switch flag.Value.Type() {
+	case "string":
+		return len(flag.DefValue) == 0
+	case "duration":
+		if flag.DefValue == "0s" {
+			return true
+		}
+	}
+	switch flag.DefValue {
+	case "", "false", "0", "<nil>", "[]":
+		return true
+	}
+	return false
+}
+
+// quoteDefault returns the default value of the flag, quoted if it is a string or if it
+// contains spaces, quotes or non-printable characters.
+func quoteDefault(flag *pflag.Flag) string {
+	needsQuote := strings.IndexFunc(flag.DefValue, func(r rune) bool {
+		return r == ' ' || r == '"' || !unicode.IsPrint(r)
+	}) >= 0
+	if needsQuote || flag.Value.Type() == "string" {
+		return strconv.Quote(flag.DefValue)
+	}
+	return flag.DefValue
+}
+
+// wrapText splits the text into lines whose display widths are at most width, at spaces.
+// The words wider than width are not split. The newlines of the text are kept, and the
+// text is not wrapped if width is zero.
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		if width <= 0 {
+			lines = append(lines, strings.TrimRight(paragraph, " "))
+			continue
+		}
+		line, lineWidth := "", 0
+		for _, word := range strings.Fields(paragraph) {
+			w := displayWidth(word)
+			if lineWidth > 0 && lineWidth+1+w > width {
+				lines = append(lines, line)
+				line, lineWidth = "", 0
+			}
+			if lineWidth > 0 {
+				line += " "
+				lineWidth++
+			}
+			line += word
+			lineWidth += w
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// displayWidth returns the number of terminal cells of s, the ANSI escape sequences,
+// e.g. colors and hyperlinks, are zero-width.
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			i += escapeLen(s[i:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += runeWidths.RuneWidth(r)
+		i += size
+	}
+	return width
+}
+
+// escapeLen returns the length of the ANSI escape sequence at the beginning of s:
+// a CSI sequence "ESC [ ... final", an OSC sequence "ESC ] ... BEL" or "ESC ] ... ESC \",
+// or ESC followed by a single character.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
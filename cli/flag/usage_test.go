@@ -0,0 +1,189 @@
+package flag
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func newUsageFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringP("name", "n", "demo", "The `name` of the component.")
+	fs.Bool("debug", false, "Enable the debug mode.")
+	fs.Duration("timeout", 30*time.Second, "The timeout of the requests which are sent to the upstream servers, "+
+		"zero means no timeout.")
+	fs.String("color", "auto", "The color mode.")
+	fs.Lookup("color").NoOptDefVal = "always"
+	fs.String("log-format", "", "Deprecated log format.")
+	_ = fs.MarkDeprecated("log-format", "use --logging-format instead")
+	fs.String("separator", " ", "The separator.")
+	fs.StringSlice("labels", nil, "")
+	fs.Int("secret", 0, "")
+	_ = fs.MarkHidden("secret")
+	return fs
+}
+
+func TestFlagUsages(t *testing.T) {
+	cases := []struct {
+		desc   string
+		cols   int
+		expect string
+	}{
+		{
+			desc: "no wrapping",
+			expect: `      --color string[="always"]   The color mode. (default "auto")
+      --debug                     Enable the debug mode.
+      --labels strings
+  -n, --name name                 The name of the component. (default "demo")
+      --separator string          The separator. (default " ")
+      --timeout duration          The timeout of the requests which are sent to the upstream servers, zero means no timeout. (default 30s)
+`,
+		},
+		{
+			desc: "wrapping",
+			cols: 72,
+			expect: `      --color string[="always"]   The color mode. (default "auto")
+      --debug                     Enable the debug mode.
+      --labels strings
+  -n, --name name                 The name of the component. (default
+                                  "demo")
+      --separator string          The separator. (default " ")
+      --timeout duration          The timeout of the requests which are
+                                  sent to the upstream servers, zero
+                                  means no timeout. (default 30s)
+`,
+		},
+		{
+			desc: "narrow",
+			cols: 48,
+			expect: `      --color string[="always"]
+                        The color mode. (default
+                        "auto")
+      --debug           Enable the debug mode.
+      --labels strings
+  -n, --name name       The name of the
+                        component. (default
+                        "demo")
+      --separator string
+                        The separator. (default
+                        " ")
+      --timeout duration
+                        The timeout of the
+                        requests which are sent
+                        to the upstream servers,
+                        zero means no timeout.
+                        (default 30s)
+`,
+		},
+		{
+			desc: "too narrow to wrap",
+			cols: 30,
+			expect: `      --color string[="always"]   The color mode. (default "auto")
+      --debug                     Enable the debug mode.
+      --labels strings
+  -n, --name name                 The name of the component. (default "demo")
+      --separator string          The separator. (default " ")
+      --timeout duration          The timeout of the requests which are sent to the upstream servers, zero means no timeout. (default 30s)
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := (UsageFormat{Cols: c.cols}).FlagUsages(newUsageFlagSet()); got != c.expect {
+				t.Fatalf("expect:\n%s\nbut got:\n%s", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestFlagUsagesDeprecated(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringP("log-format", "f", "", "The log format.")
+	_ = fs.MarkShorthandDeprecated("log-format", "use --log-format instead")
+	fs.String("logging", "", "The logging.")
+	_ = fs.MarkDeprecated("logging", "use --log-format instead")
+	fs.Lookup("logging").Hidden = false
+
+	expect := `      --log-format string   The log format.
+      --logging string      The logging. (DEPRECATED: use --log-format instead)
+`
+	if got := (UsageFormat{}).FlagUsages(fs); got != expect {
+		t.Fatalf("expect:\n%s\nbut got:\n%s", expect, got)
+	}
+}
+
+func TestFlagUsagesWidth(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("名前", "", "コンポーネントの 名前 です。 説明 は 長い です。")
+	fs.Bool("color", false, "\x1b[1mBold\x1b[0m usage of the \x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\ with escapes.")
+
+	expect := "      --color         \x1b[1mBold\x1b[0m usage of the \x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\n" +
+		"                      with escapes.\n" +
+		"      --名前 string   コンポーネントの 名前\n" +
+		"                      です。 説明 は 長い\n" +
+		"                      です。\n"
+	if got := (UsageFormat{Cols: 46}).FlagUsages(fs); got != expect {
+		t.Fatalf("expect:\n%q\nbut got:\n%q", expect, got)
+	}
+}
+
+func TestUsageFormatPrintSections(t *testing.T) {
+	var fss NamedFlagSets
+	fss.FlagSet("generic").Bool("debug", false, "Enable the debug mode.")
+	fss.FlagSet("secure serving").Int("secure-port", 443, "The port on which to serve HTTPS.")
+	fss.FlagSet("empty")
+
+	cases := []struct {
+		desc   string
+		format UsageFormat
+		expect string
+	}{
+		{
+			desc: "aligned per section",
+			expect: `
+Generic flags:
+      --debug   Enable the debug mode.
+
+Secure serving flags:
+      --secure-port int   The port on which to serve HTTPS. (default 443)
+`,
+		},
+		{
+			desc:   "aligned globally",
+			format: UsageFormat{AlignSections: true},
+			expect: `
+Generic flags:
+      --debug             Enable the debug mode.
+
+Secure serving flags:
+      --secure-port int   The port on which to serve HTTPS. (default 443)
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			c.format.PrintSections(&buf, fss)
+			if buf.String() != c.expect {
+				t.Fatalf("expect:\n%s\nbut got:\n%s", c.expect, buf.String())
+			}
+		})
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	cases := map[string]int{
+		"abc":                              3,
+		"名前":                               4,
+		"\x1b[31mred\x1b[0m":               3,
+		"\x1b]8;;http://a\alink\x1b]8;;\a": 4,
+		"\x1b":                             0,
+	}
+	for s, expect := range cases {
+		if got := displayWidth(s); got != expect {
+			t.Errorf("expect width %d of %q but got %d", expect, s, got)
+		}
+	}
+}
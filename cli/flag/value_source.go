@@ -0,0 +1,171 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/pflag"
+
+	"github.com/shipengqi/component-base/json"
+)
+
+// ConfigAnnotation is the flag annotation which records the config file a flag value has been
+// set from, the first element is the file path and the second one is the line, if it is known.
+const ConfigAnnotation = "cliflag.config"
+
+// PrintEffectiveConfigFlagName is the name of the flag which prints the effective config.
+const PrintEffectiveConfigFlagName = "print-effective-config"
+
+const (
+	// EffectiveConfigFormatTable prints the effective config as a table.
+	EffectiveConfigFormatTable = "table"
+	// EffectiveConfigFormatJSON prints the effective config as JSON.
+	EffectiveConfigFormatJSON = "json"
+)
+
+// ValueSourceType is the type of the source a flag value comes from.
+type ValueSourceType string
+
+const (
+	// SourceDefault is the default value of the flag.
+	SourceDefault ValueSourceType = "default"
+	// SourceConfigFile is a value set from a config file.
+	SourceConfigFile ValueSourceType = "config"
+	// SourceEnv is a value set from an environment variable.
+	SourceEnv ValueSourceType = "env"
+	// SourceCommandLine is a value set on the command line.
+	SourceCommandLine ValueSourceType = "command-line"
+)
+
+// ValueSource describes where a flag value comes from.
+type ValueSource struct {
+	// Type is the type of the source.
+	Type ValueSourceType `json:"type"`
+	// Location is the config file path, followed by ":" and the line if it is known,
+	// or the environment variable name. It is empty for the other types.
+	Location string `json:"location,omitempty"`
+}
+
+// String returns the type of the source, followed by the location if there is one.
+func (s ValueSource) String() string {
+	if len(s.Location) == 0 {
+		return string(s.Type)
+	}
+	return fmt.Sprintf("%s (%s)", s.Type, s.Location)
+}
+
+// FlagValueSource returns the source of the flag value. The command line takes precedence over
+// the environment variables recorded by SetFromEnv, which take precedence over the config
+// files recorded in the ConfigAnnotation.
+func FlagValueSource(flag *pflag.Flag) ValueSource {
+	if flag.Changed {
+		return ValueSource{Type: SourceCommandLine}
+	}
+	if envName, ok := FlagSetFromEnv(flag); ok {
+		return ValueSource{Type: SourceEnv, Location: envName}
+	}
+	if location := flag.Annotations[ConfigAnnotation]; len(location) > 0 {
+		source := ValueSource{Type: SourceConfigFile, Location: location[0]}
+		if len(location) > 1 && location[1] != "0" {
+			source.Location += ":" + location[1]
+		}
+		return source
+	}
+	return ValueSource{Type: SourceDefault}
+}
+
+// EffectiveValue is the effective value of a flag and its source.
+type EffectiveValue struct {
+	// Section is the name of the flag section.
+	Section string `json:"section"`
+	// Flag is the name of the flag.
+	Flag string `json:"flag"`
+	// Value is the string of the flag value.
+	Value string `json:"value"`
+	// Source is where the value comes from.
+	Source ValueSource `json:"source"`
+}
+
+// EffectiveValues returns the effective values of all flags, in the order of the sections
+// and the order in which their flag sets visit the flags.
+func EffectiveValues(nfs NamedFlagSets) []EffectiveValue {
+	var values []EffectiveValue
+	for _, name := range nfs.Order {
+		nfs.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			values = append(values, EffectiveValue{
+				Section: name,
+				Flag:    flag.Name,
+				Value:   flag.Value.String(),
+				Source:  FlagValueSource(flag),
+			})
+		})
+	}
+	return values
+}
+
+// PrintEffectiveConfig prints the effective value, the source and the section of all flags,
+// in EffectiveConfigFormatTable or EffectiveConfigFormatJSON format.
+func PrintEffectiveConfig(w io.Writer, nfs NamedFlagSets, format string) error {
+	values := EffectiveValues(nfs)
+	switch format {
+	case EffectiveConfigFormatTable:
+		table := uitable.New()
+		table.Separator = "  "
+		table.AddRow("SECTION", "FLAG", "VALUE", "SOURCE")
+		for _, v := range values {
+			table.AddRow(v.Section, "--"+v.Flag, strconv.Quote(v.Value), v.Source)
+		}
+		_, err := fmt.Fprintln(w, table)
+		return err
+	case EffectiveConfigFormatJSON:
+		if values == nil {
+			values = []EffectiveValue{}
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	default:
+		return fmt.Errorf("unknown effective config format %q, possible values: %s", format,
+			strings.Join([]string{EffectiveConfigFormatTable, EffectiveConfigFormatJSON}, ", "))
+	}
+}
+
+// AddPrintEffectiveConfigFlag adds the --print-effective-config flag to the specified FlagSet,
+// the format is stored in p. "--print-effective-config" is treated as
+// "--print-effective-config=table".
+func AddPrintEffectiveConfigFlag(fs *pflag.FlagSet, p *string) {
+	fs.Var(&effectiveConfigFormatValue{value: p}, PrintEffectiveConfigFlagName, ""+
+		"Print the effective value, the source and the section of every flag and exit. "+
+		"Possible formats: "+EffectiveConfigFormatTable+", "+EffectiveConfigFormatJSON+".")
+	fs.Lookup(PrintEffectiveConfigFlagName).NoOptDefVal = EffectiveConfigFormatTable
+}
+
+// effectiveConfigFormatValue implements pflag.Value for the effective config format.
+type effectiveConfigFormatValue struct {
+	value *string
+}
+
+func (v *effectiveConfigFormatValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *effectiveConfigFormatValue) Set(value string) error {
+	if value != EffectiveConfigFormatTable && value != EffectiveConfigFormatJSON {
+		return fmt.Errorf("unknown format %q", value)
+	}
+	*v.value = value
+	return nil
+}
+
+func (*effectiveConfigFormatValue) Type() string {
+	return "string"
+}
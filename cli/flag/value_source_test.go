@@ -0,0 +1,112 @@
+package flag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shipengqi/component-base/json"
+)
+
+func newSourceFlagSets(t *testing.T) NamedFlagSets {
+	t.Helper()
+	nfs := NamedFlagSets{EnvPrefix: "SRCAPP"}
+	fs := nfs.FlagSet("secure serving")
+	fs.Int("secure-port", 443, "")
+	fs.String("bind-address", "0.0.0.0", "")
+	generic := nfs.FlagSet("generic")
+	generic.Bool("debug", false, "")
+	generic.String("log-level", "info", "")
+
+	t.Setenv("SRCAPP_BIND_ADDRESS", "10.0.0.1")
+	t.Setenv("SRCAPP_SECURE_PORT", "9443")
+	if err := fs.Parse([]string{"--secure-port=8443"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nfs.SetFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	debug := generic.Lookup("debug")
+	_ = debug.Value.Set("true")
+	debug.Annotations = map[string][]string{ConfigAnnotation: {"/etc/app.json", "3"}}
+	return nfs
+}
+
+func TestFlagValueSource(t *testing.T) {
+	nfs := newSourceFlagSets(t)
+	cases := []struct {
+		section string
+		flag    string
+		expect  ValueSource
+		str     string
+	}{
+		{"secure serving", "secure-port", ValueSource{Type: SourceCommandLine}, "command-line"},
+		{"secure serving", "bind-address", ValueSource{Type: SourceEnv, Location: "SRCAPP_BIND_ADDRESS"}, "env (SRCAPP_BIND_ADDRESS)"},
+		{"generic", "debug", ValueSource{Type: SourceConfigFile, Location: "/etc/app.json:3"}, "config (/etc/app.json:3)"},
+		{"generic", "log-level", ValueSource{Type: SourceDefault}, "default"},
+	}
+	for _, c := range cases {
+		t.Run(c.flag, func(t *testing.T) {
+			got := FlagValueSource(nfs.FlagSets[c.section].Lookup(c.flag))
+			if got != c.expect {
+				t.Fatalf("expect %+v but got %+v", c.expect, got)
+			}
+			if got.String() != c.str {
+				t.Fatalf("expect %q but got %q", c.str, got.String())
+			}
+		})
+	}
+}
+
+func TestPrintEffectiveConfig(t *testing.T) {
+	nfs := newSourceFlagSets(t)
+
+	var table bytes.Buffer
+	if err := PrintEffectiveConfig(&table, nfs, EffectiveConfigFormatTable); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expect a header and 4 rows, got\n%s", table.String())
+	}
+	for i, expect := range []string{"SECTION", "secure serving  --bind-address", "secure serving  --secure-port",
+		"generic         --debug", "generic         --log-level"} {
+		if !strings.HasPrefix(lines[i], expect) {
+			t.Errorf("expect line %d to start with %q, got %q", i, expect, lines[i])
+		}
+	}
+
+	var out bytes.Buffer
+	if err := PrintEffectiveConfig(&out, nfs, EffectiveConfigFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var values []EffectiveValue
+	if err := json.Unmarshal(out.Bytes(), &values); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 || values[2] != (EffectiveValue{Section: "generic", Flag: "debug", Value: "true",
+		Source: ValueSource{Type: SourceConfigFile, Location: "/etc/app.json:3"}}) {
+		t.Fatalf("unexpected values %+v", values)
+	}
+
+	if err := PrintEffectiveConfig(&out, nfs, "yaml"); err == nil {
+		t.Fatal("expect an error for an unknown format")
+	}
+}
+
+func TestAddPrintEffectiveConfigFlag(t *testing.T) {
+	var format string
+	nfs := NamedFlagSets{}
+	fs := nfs.FlagSet("global")
+	AddPrintEffectiveConfigFlag(fs, &format)
+
+	if err := fs.Parse([]string{"--print-effective-config"}); err != nil || format != EffectiveConfigFormatTable {
+		t.Fatalf("expect the table format, got %q, %v", format, err)
+	}
+	if err := fs.Parse([]string{"--print-effective-config=json"}); err != nil || format != EffectiveConfigFormatJSON {
+		t.Fatalf("expect the json format, got %q, %v", format, err)
+	}
+	if err := fs.Set(PrintEffectiveConfigFlagName, "yaml"); err == nil {
+		t.Fatal("expect an error for an unknown format")
+	}
+}
@@ -0,0 +1,6 @@
+// Package config loads the flag values of NamedFlagSets from config files,
+// the top-level keys are the section names and the nested keys are the flag names.
+// Versioned config files with apiVersion and kind are decoded by a Scheme,
+// which converts them into the internal types. The config files are validated against
+// the JSON schema generated from the flags before any flag is set.
+package config
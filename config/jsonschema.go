@@ -0,0 +1,307 @@
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/json"
+)
+
+// SchemaDialect is the JSON Schema dialect of the generated schemas.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema used to describe config files.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Deprecated           bool                   `json:"deprecated,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// schemaTypes are the JSON types of a JSON schema, a single type is encoded as a string.
+type schemaTypes []string
+
+func (t schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// scalarTypes are the JSON types of the flag types whose values are not strings, the flags
+// of the other types which are neither slices nor maps are strings.
+var scalarTypes = map[string]string{
+	"bool": "boolean", "tristate": "boolean",
+	"int": "integer", "int8": "integer", "int16": "integer", "int32": "integer", "int64": "integer",
+	"uint": "integer", "uint8": "integer", "uint16": "integer", "uint32": "integer", "uint64": "integer",
+	"count": "integer", "float32": "number", "float64": "number",
+}
+
+// mapTypes are the JSON schemas of the values of the flag types which are objects.
+var mapTypes = map[string]*jsonSchema{
+	"mapStringString":                    {Type: schemaTypes{"string", "null"}},
+	"mapStringBool":                      {Type: schemaTypes{"boolean", "null"}},
+	"colonSeparatedMultimapStringString": {Type: schemaTypes{"string", "array", "null"}, Items: &jsonSchema{Type: schemaTypes{"string"}}},
+	"stringToString":                     {Type: schemaTypes{"string", "null"}},
+	"stringToInt":                        {Type: schemaTypes{"integer", "null"}},
+	"stringToInt64":                      {Type: schemaTypes{"integer", "null"}},
+}
+
+// Schema returns a JSON Schema document of the unversioned config files of the flags of nfs, see LoadFile.
+// The sections are objects of the flags of the flag sets, and "profiles" is an object of the
+// documents of the profiles. The JSON type of each flag is derived from pflag.Value.Type(),
+// e.g. "mapStringString" is an object of strings, and slices are arrays, which can be appended
+// to with the AppendSuffix. The usage is the description and the default value is the default.
+// The possible values of the flags implementing cliflag.EnumValue are enumerated.
+// The flags of Options, e.g. --config, are not described.
+func Schema(nfs *cliflag.NamedFlagSets) ([]byte, error) {
+	return json.MarshalIndent(schemaOf(nfs), "", "  ")
+}
+
+// schemaOf returns the JSON schema of the config files of the flags of nfs, the sections are in $defs.
+func schemaOf(nfs *cliflag.NamedFlagSets) *jsonSchema {
+	sections := map[string]*jsonSchema{}
+	profile := &jsonSchema{
+		Type:                 schemaTypes{"object"},
+		Properties:           map[string]*jsonSchema{},
+		AdditionalProperties: false,
+	}
+	root := &jsonSchema{
+		Schema:               SchemaDialect,
+		Type:                 schemaTypes{"object"},
+		Properties:           map[string]*jsonSchema{ProfilesKey: {Type: schemaTypes{"object"}, AdditionalProperties: profile}},
+		AdditionalProperties: false,
+		Defs:                 sections,
+	}
+	for _, name := range nfs.Order {
+		section := &jsonSchema{
+			Type:                 schemaTypes{"object"},
+			Properties:           map[string]*jsonSchema{},
+			AdditionalProperties: false,
+		}
+		nfs.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if optionsFlag(flag.Name) {
+				return
+			}
+			s := flagSchema(flag)
+			section.Properties[flag.Name] = s
+			if s.Items != nil {
+				section.Properties[flag.Name+AppendSuffix] = &jsonSchema{
+					Type:        schemaTypes{"array"},
+					Description: fmt.Sprintf("Appended to %q.", flag.Name),
+					Items:       s.Items,
+				}
+			}
+		})
+		if len(section.Properties) == 0 {
+			continue
+		}
+		sections[name] = section
+		ref := &jsonSchema{Ref: (&url.URL{Fragment: pointer("$defs", name)}).String()}
+		root.Properties[name] = ref
+		profile.Properties[name] = ref
+	}
+	return root
+}
+
+// WriteSchema writes the JSON Schema document of the config files of the flags of nfs to w, see Schema.
+func WriteSchema(w io.Writer, nfs *cliflag.NamedFlagSets) error {
+	data, err := Schema(nfs)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// StructSchema returns the JSON Schema document of the config files of the flags bound to the
+// struct pointed to by opts, see cliflag.BindStruct and Schema.
+func StructSchema(opts any) ([]byte, error) {
+	var nfs cliflag.NamedFlagSets
+	if err := cliflag.BindStruct(&nfs, opts); err != nil {
+		return nil, err
+	}
+	return Schema(&nfs)
+}
+
+// flagSchema returns the JSON schema of the flag value, null removes the value of lower layers.
+func flagSchema(flag *pflag.Flag) *jsonSchema {
+	s := &jsonSchema{Description: strings.TrimSpace(flag.Usage), Deprecated: len(flag.Deprecated) > 0}
+	typ := flag.Value.Type()
+
+	var possible []string
+	if enum, ok := flag.Value.(cliflag.EnumValue); ok {
+		possible = enum.PossibleValues()
+	}
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		items := &jsonSchema{Type: schemaTypes{elemType(typ)}}
+		for _, value := range possible {
+			items.Enum = append(items.Enum, value)
+		}
+		// a string is set as the command line value
+		s.Type = schemaTypes{"array", "string", "null"}
+		s.Items = items
+		if cliflag.FlagValueSource(flag).Type == cliflag.SourceDefault {
+			defaults := make([]interface{}, 0, len(slice.GetSlice()))
+			for _, elem := range slice.GetSlice() {
+				defaults = append(defaults, typedValue(elem, items.Type[0]))
+			}
+			s.Default = defaults
+		}
+		return s
+	}
+	if values, ok := mapTypes[typ]; ok {
+		// a string is set as the command line value, e.g. "a=b,c=d"
+		s.Type = schemaTypes{"object", "string", "null"}
+		s.AdditionalProperties = values
+		s.PropertyNames = &jsonSchema{Pattern: "^[^" + regexp.QuoteMeta(mapSeparators(flag.Value)) + "]*$"}
+		if cliflag.FlagValueSource(flag).Type == cliflag.SourceDefault {
+			s.Default = mapDefault(flag.Value)
+		}
+		return s
+	}
+
+	jsonType, ok := scalarTypes[typ]
+	if !ok {
+		jsonType = "string"
+	}
+	s.Type = schemaTypes{jsonType, "null"}
+	if strings.HasPrefix(typ, "uint") || typ == "count" {
+		minimum := 0
+		s.Minimum = &minimum
+	}
+	if len(possible) > 0 {
+		for _, value := range possible {
+			s.Enum = append(s.Enum, typedValue(value, jsonType))
+		}
+		s.Enum = append(s.Enum, nil)
+	}
+	if len(flag.DefValue) > 0 || jsonType == "string" {
+		s.Default = typedValue(flag.DefValue, jsonType)
+	}
+	return s
+}
+
+// elemType returns the JSON type of the elements of the slice flag type, e.g. "intSlice".
+func elemType(typ string) string {
+	elem := strings.TrimSuffix(typ, "Slice")
+	if jsonType, ok := scalarTypes[elem]; ok && elem != typ {
+		return jsonType
+	}
+	return "string"
+}
+
+// typedValue returns the value of the string of the JSON type, or the string if it can't be parsed.
+func typedValue(s string, jsonType string) interface{} {
+	switch jsonType {
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+	}
+	return s
+}
+
+// mapSeparators returns the separators of the key-value pairs of the map flag value,
+// which can't be in the keys, see mapPairs.
+func mapSeparators(value pflag.Value) string {
+	switch v := value.(type) {
+	case *cliflag.ColonSeparatedMultimapStringString:
+		return ",:"
+	case *cliflag.LangleSeparatedMapStringString:
+		return ",<"
+	case *cliflag.MapStringString:
+		if v.NoSplit {
+			return "="
+		}
+	}
+	return ",="
+}
+
+// mapDefault returns the current value of the map flag value, or nil if it is unknown.
+func mapDefault(value pflag.Value) interface{} {
+	switch v := value.(type) {
+	case *cliflag.MapStringString:
+		return derefMap(v.Map)
+	case *cliflag.LangleSeparatedMapStringString:
+		return derefMap(v.Map)
+	case *cliflag.MapStringBool:
+		return derefMap(v.Map)
+	case *cliflag.ColonSeparatedMultimapStringString:
+		return derefMap(v.Multimap)
+	}
+	switch value.Type() {
+	case "stringToString":
+		if m, ok := pflagMap(value, true); ok {
+			return m
+		}
+	case "stringToInt", "stringToInt64":
+		if m, ok := pflagMap(value, false); ok {
+			ints := make(map[string]json.Number, len(m))
+			for k, v := range m {
+				ints[k] = json.Number(v)
+			}
+			return ints
+		}
+	}
+	return nil
+}
+
+// pflagMap returns the map of the map flag value of pflag, whose String is "[a=1,b=2]",
+// the pairs are CSV encoded if quoted is true.
+func pflagMap(value pflag.Value, quoted bool) (map[string]string, bool) {
+	s := value.String()
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, false
+	}
+	s = s[1 : len(s)-1]
+	m := map[string]string{}
+	if len(s) == 0 {
+		return m, true
+	}
+	pairs := strings.Split(s, ",")
+	if quoted {
+		var err error
+		if pairs, err = csv.NewReader(strings.NewReader(s)).Read(); err != nil {
+			return nil, false
+		}
+	}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, false
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, true
+}
+
+func derefMap[V any](m *map[string]V) interface{} {
+	if m == nil || *m == nil {
+		return map[string]V{}
+	}
+	return *m
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/json"
+)
+
+// lookupSchema returns the value of the JSON pointer in the schema, or nil if it is not found.
+func lookupSchema(schema map[string]interface{}, ptr string) interface{} {
+	var value interface{} = schema
+	for _, token := range strings.Split(ptr, "/")[1:] {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[token]
+	}
+	return value
+}
+
+func TestSchema(t *testing.T) {
+	var (
+		f       layeredFlags
+		ciphers []string
+		format       = "table"
+		workers uint = 4
+	)
+	nfs := newLayeredFlagSets(&f)
+	NewOptions().AddFlags(nfs.FlagSet("global"))
+	serving := nfs.FlagSets["secure serving"]
+	serving.Var(cliflag.NewTLSCipherSuitesValue(&ciphers), "tls-cipher-suites", "Comma-separated list of cipher suites.")
+	generic := nfs.FlagSets["generic"]
+	generic.Var(cliflag.NewEnum(&format, "table", "json"), "format", "The output format.")
+	generic.UintVar(&workers, "workers", workers, "")
+	generic.Var(cliflag.NewMapStringBool(new(map[string]bool)), "feature-gates", "")
+	generic.String("old", "", "")
+	_ = generic.MarkDeprecated("old", "use --format instead")
+
+	var buf bytes.Buffer
+	if err := WriteSchema(&buf, nfs); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]interface{}{
+		"/$schema":                        SchemaDialect,
+		"/properties/secure serving/$ref": "#/$defs/secure%20serving",
+		"/properties/profiles/additionalProperties/properties/misc/$ref": "#/$defs/misc",
+		"/properties/global":                                                nil,
+		"/$defs/secure serving/additionalProperties":                        false,
+		"/$defs/secure serving/properties/secure-port/type":                 []interface{}{"integer", "null"},
+		"/$defs/secure serving/properties/secure-port/default":              float64(443),
+		"/$defs/secure serving/properties/bind-address/default":             "0.0.0.0",
+		"/$defs/secure serving/properties/tls-cipher-suites/type":           []interface{}{"array", "string", "null"},
+		"/$defs/secure serving/properties/tls-cipher-suites/description":    "Comma-separated list of cipher suites.",
+		"/$defs/secure serving/properties/tls-cipher-suites+/type":          "array",
+		"/$defs/generic/properties/debug/default":                           false,
+		"/$defs/generic/properties/names/default":                           []interface{}{"default"},
+		"/$defs/generic/properties/names/items/type":                        "string",
+		"/$defs/generic/properties/labels/type":                             []interface{}{"object", "string", "null"},
+		"/$defs/generic/properties/labels/additionalProperties/type":        []interface{}{"string", "null"},
+		"/$defs/generic/properties/feature-gates/additionalProperties/type": []interface{}{"boolean", "null"},
+		"/$defs/generic/properties/format/enum":                             []interface{}{"table", "json", nil},
+		"/$defs/generic/properties/format/default":                          "table",
+		"/$defs/generic/properties/workers/minimum":                         float64(0),
+		"/$defs/generic/properties/old/deprecated":                          true,
+		"/$defs/misc/properties/multimap/additionalProperties/items/type":   "string",
+	}
+	for ptr, expect := range cases {
+		if got := lookupSchema(schema, ptr); !reflect.DeepEqual(got, expect) {
+			t.Errorf("expect %v at %s but got %v", expect, ptr, got)
+		}
+	}
+
+	enum, _ := lookupSchema(schema, "/$defs/secure serving/properties/tls-cipher-suites/items/enum").([]interface{})
+	if len(enum) != len(cliflag.TLSCipherPossibleValues()) || enum[0] != cliflag.TLSCipherPossibleValues()[0] {
+		t.Errorf("expect the cipher suite names to be enumerated, got %v", enum)
+	}
+}
+
+func TestStructSchema(t *testing.T) {
+	opts := &struct {
+		Port    int      `flag:"secure-port" usage:"The port." section:"serving"`
+		Ratios  []int    `flag:"ratios"`
+		Unnamed chan int `flag:"unnamed"`
+	}{Port: 443}
+	if _, err := StructSchema(opts); err == nil {
+		t.Fatal("expect an error for the unsupported type")
+	}
+
+	data, err := StructSchema(&struct {
+		Port   int   `flag:"secure-port" usage:"The port." section:"serving"`
+		Ratios []int `flag:"ratios"`
+	}{Port: 443, Ratios: []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]interface{}{
+		"/$defs/serving/properties/secure-port/description": "The port.",
+		"/$defs/serving/properties/secure-port/default":     float64(443),
+		"/$defs/generic/properties/ratios/items/type":       "integer",
+		"/$defs/generic/properties/ratios/default":          []interface{}{float64(1), float64(2)},
+	}
+	for ptr, expect := range cases {
+		if got := lookupSchema(schema, ptr); !reflect.DeepEqual(got, expect) {
+			t.Errorf("expect %v at %s but got %v", expect, ptr, got)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/json"
+)
+
+const (
+	// FlagName is the name of the flag which specifies the config files.
+	FlagName = "config"
+	// ProfileFlagName is the name of the flag which selects the profile overlay of the config files.
+	ProfileFlagName = "profile"
+	// Annotation is the flag annotation which records the config file a flag value has been set from,
+	// and the line of the flag in the file if it is known.
+	Annotation = cliflag.ConfigAnnotation
+	// ProfilesKey is the top-level key of a config file which maps the profile names into
+	// the overlays, it can't be used as a section name.
+	ProfilesKey = "profiles"
+	// AppendSuffix is the suffix of a flag name in a config file which appends the array
+	// to the array of the earlier config files, instead of replacing it.
+	AppendSuffix = "+"
+)
+
+// document is a decoded config file, the section names are mapped into the flag names,
+// which are mapped into the flag values.
+type document map[string]map[string]interface{}
+
+// layer is a document merged by LoadFiles, a config file or a profile overlay in it.
+type layer struct {
+	doc  document
+	path string
+	// pointer is the JSON pointer of the document in the config file, it is empty for the file itself.
+	pointer string
+	// positions are the positions of the values in the config file, by their JSON pointers.
+	// They are unknown for the versioned config files.
+	positions map[string]position
+}
+
+// origin returns the origin of the flag in the config file.
+func (l layer) origin(sectionName, key string) origin {
+	ptr := l.pointer + pointer(sectionName, key)
+	pos := l.positions[ptr]
+	return origin{path: l.path, pointer: ptr, line: pos.line, column: pos.column}
+}
+
+// origin is the config file, the JSON pointer and the position a merged flag value comes from.
+type origin struct {
+	path    string
+	pointer string
+	// line and column are 0 if the position is unknown.
+	line   int
+	column int
+}
+
+// String returns the path, followed by ":" and the line if it is known.
+func (o origin) String() string {
+	if o.line == 0 {
+		return o.path
+	}
+	return fmt.Sprintf("%s:%d", o.path, o.line)
+}
+
+// error returns the ValidationError of the value from the origin.
+func (o origin) error(format string, args ...interface{}) error {
+	return &ValidationError{
+		Path:    o.path,
+		Pointer: o.pointer,
+		Line:    o.line,
+		Column:  o.column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Options contains the options for loading config files.
+type Options struct {
+	// Paths are the paths of the JSON config files, they are merged in order.
+	// No config file is loaded if it is empty.
+	Paths []string
+	// Profile is the name of the profile overlay in the config files which is merged
+	// on top of the file containing it.
+	Profile string
+	// WriteConfigTo is the path to write the current flag values to as a config file.
+	WriteConfigTo string
+	// ValidateConfig validates the config files and exits, see ValidateIfRequested.
+	ValidateConfig bool
+	// Scheme decodes the versioned config files, see LoadFilesWithScheme.
+	// Only unversioned config files are accepted if it is nil.
+	Scheme *Scheme
+}
+
+// NewOptions creates an Options with default parameters.
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// AddFlags adds the --config, --profile, --write-config-to and --validate-config flags to the specified FlagSet,
+// which is usually the global section of NamedFlagSets.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+
+	fs.StringSliceVar(&o.Paths, FlagName, o.Paths, "The paths to the JSON config files, "+
+		"the later files are deep merged on top of the earlier ones. "+
+		"The top-level keys are the flag section names and the nested keys are the flag names, "+
+		"e.g. {\"secure serving\": {\"tls-min-version\": \"VersionTLS12\"}}. "+
+		"Flags set on the command line or from environment variables take precedence over the config files.")
+
+	fs.StringVar(&o.Profile, ProfileFlagName, o.Profile, "The name of the profile to select from the "+
+		"\""+ProfilesKey+"\" key of the config files, the profile overlay is merged on top of the file containing it.")
+
+	fs.StringVar(&o.WriteConfigTo, WriteFlagName, o.WriteConfigTo, "If set, write the current flag values "+
+		"to this file as a commented config file for --"+FlagName+".")
+
+	fs.BoolVar(&o.ValidateConfig, ValidateFlagName, o.ValidateConfig, "If true, validate the config files "+
+		"of --"+FlagName+", report all violations and exit without starting.")
+}
+
+// Validate checks validation of Options.
+func (o *Options) Validate() []error {
+	if o == nil {
+		return nil
+	}
+
+	var errs []error
+	if len(o.Profile) > 0 && len(o.Paths) == 0 {
+		errs = append(errs, fmt.Errorf("--%s requires --%s", ProfileFlagName, FlagName))
+	}
+	if o.ValidateConfig && len(o.Paths) == 0 {
+		errs = append(errs, fmt.Errorf("--%s requires --%s", ValidateFlagName, FlagName))
+	}
+	return errs
+}
+
+// Load applies the config files to the flags of nfs, see LoadFilesWithScheme.
+func (o *Options) Load(nfs *cliflag.NamedFlagSets) error {
+	if o == nil || len(o.Paths) == 0 {
+		return nil
+	}
+	return LoadFilesWithScheme(nfs, o.Scheme, o.Paths, o.Profile)
+}
+
+// ValidateIfRequested validates the config files if ValidateConfig is set, see ValidateFiles.
+// It returns true if the config files are validated, the component is expected to exit then,
+// with a non-zero code if there is an error.
+func (o *Options) ValidateIfRequested(nfs *cliflag.NamedFlagSets) (bool, error) {
+	if o == nil || !o.ValidateConfig {
+		return false, nil
+	}
+	return true, ValidateFiles(nfs, o.Scheme, o.Paths, o.Profile)
+}
+
+// WriteIfRequested writes the current flag values of nfs to WriteConfigTo if it is set,
+// see WriteFile. It returns true if the config file is written, the component is expected
+// to exit then.
+func (o *Options) WriteIfRequested(nfs *cliflag.NamedFlagSets) (bool, error) {
+	if o == nil || len(o.WriteConfigTo) == 0 {
+		return false, nil
+	}
+	if err := WriteFile(nfs, o.WriteConfigTo); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LoadFile reads the JSON config file at path and sets the flags of nfs from it,
+// it is LoadFiles with a single file and no profile.
+func LoadFile(nfs *cliflag.NamedFlagSets, path string) error {
+	return LoadFiles(nfs, []string{path}, "")
+}
+
+// LoadFiles reads the JSON config files at paths, deep merges them in order and sets
+// the flags of nfs from the result. It should be called after the command line is parsed
+// and NamedFlagSets.SetFromEnv is called, the flags which are set on the command line or
+// from environment variables are skipped, so the precedence is command line over environment
+// over config files over default.
+//
+// If profile is not empty, the overlay with that name under the ProfilesKey of a config file
+// is merged on top of the file, before the later files. It is an error if none of the files
+// contains the profile.
+//
+// The values of a later file are merged into the values of the earlier files:
+//   - objects are merged by key, null removes the key;
+//   - arrays replace the earlier arrays, unless the flag name has the AppendSuffix,
+//     e.g. "names+": ["c"] appends "c" to the earlier "names";
+//   - null removes the earlier value, the flag keeps its default then;
+//   - other values replace the earlier values.
+//
+// The merged values are set through the Set method of each flag:
+//   - strings, numbers and booleans are passed as they are written;
+//   - arrays replace the values of flags implementing pflag.SliceValue,
+//     otherwise Set is called once for each element, like a repeated flag;
+//   - objects are passed as key=value pairs sorted by key, key:value for
+//     cliflag.ColonSeparatedMultimapStringString whose values can be arrays, and
+//     key<value for cliflag.LangleSeparatedMapStringString. Set is called once for
+//     each pair, so the pairs are merged like repeated flags.
+//
+// Line comments starting with "//" are allowed, e.g. in the files generated by WriteFile.
+//
+// The config files are validated against the JSON schema of the flags before any flag is set,
+// see Schema. The flags keep their Changed state, and the last config file setting a flag is
+// recorded in the Annotation. All violations, e.g. unknown sections, unknown flags and values
+// of wrong types, are reported together as ValidationErrors with the JSON pointers and the
+// positions of the values, no flag is set if any config file has a violation. The values
+// which are rejected by the Set method of the flags are reported together as well.
+func LoadFiles(nfs *cliflag.NamedFlagSets, paths []string, profile string) error {
+	return LoadFilesWithScheme(nfs, nil, paths, profile)
+}
+
+// LoadFilesWithScheme is like LoadFiles, but the config files with apiVersion and kind are
+// decoded by the scheme into their internal types, with the defaults of each version set.
+// The internal objects are encoded to JSON and used like unversioned config files, so the
+// JSON shape of an internal type is the sections mapped into the flag names, e.g.
+//
+//	type Configuration struct {
+//		SecureServing SecureServing `json:"secure serving"`
+//	}
+//
+//	type SecureServing struct {
+//		SecurePort int `json:"secure-port,omitempty"`
+//	}
+//
+// Use omitempty for the fields which keep the flag defaults when they are not set.
+// Versioned config files can't have profiles.
+func LoadFilesWithScheme(nfs *cliflag.NamedFlagSets, scheme *Scheme, paths []string, profile string) error {
+	return load(nfs, scheme, paths, profile, false)
+}
+
+// ValidateFiles validates the config files like LoadFilesWithScheme, the values of all flags
+// including the ones set on the command line or from environment variables are set from the
+// config files, so that every value is checked by its Set method. It is meant to be called
+// before the component exits, without starting it.
+func ValidateFiles(nfs *cliflag.NamedFlagSets, scheme *Scheme, paths []string, profile string) error {
+	return load(nfs, scheme, paths, profile, true)
+}
+
+// load merges the config files and sets the flags of nfs from the result, all flags are set
+// if force is true, otherwise only the overridable ones.
+func load(nfs *cliflag.NamedFlagSets, scheme *Scheme, paths []string, profile string, force bool) error {
+	var (
+		errs         []error
+		profileFound bool
+	)
+	merged := document{}
+	origins := map[string]map[string]origin{}
+	for _, path := range paths {
+		layers, found, err := readFile(nfs, path, profile, scheme)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		profileFound = profileFound || found
+		for _, l := range layers {
+			errs = append(errs, merge(merged, origins, l)...)
+		}
+	}
+	if len(profile) > 0 && !profileFound && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("profile %q not found in config files %s", profile, strings.Join(paths, ", ")))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return apply(nfs, merged, origins, force)
+}
+
+// FlagSetFromConfig returns the config file a flag value has been set from by LoadFiles.
+func FlagSetFromConfig(flag *pflag.Flag) (string, bool) {
+	if paths, ok := flag.Annotations[Annotation]; ok && len(paths) > 0 {
+		return paths[0], true
+	}
+	return "", false
+}
+
+// readFile reads the config file at path and returns its layers, the file itself and
+// the overlay of the profile if the file contains it. The versioned config files are
+// converted into their internal types by the scheme. The violations of the file are
+// returned as the error.
+func readFile(nfs *cliflag.NamedFlagSets, path, profile string, scheme *Scheme) ([]layer, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to read config file: %v", err)
+	}
+	data, versioned, err := internalData(data, scheme)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to decode config file %s: %v", path, err)
+	}
+	doc, profiles, err := decode(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to decode config file %s: %v", path, err)
+	}
+
+	var dataPositions map[string]position
+	if !versioned {
+		dataPositions = positions(data)
+	}
+	var raw map[string]interface{}
+	if err = json.Unmarshal(stripComments(data), &raw); err != nil {
+		return nil, false, fmt.Errorf("unable to decode config file %s: %v", path, err)
+	}
+	if violations := validateData(nfs, path, raw, dataPositions); len(violations) > 0 {
+		return nil, false, errors.Join(violations...)
+	}
+	layers := []layer{{doc: doc, path: path, positions: dataPositions}}
+	if len(profile) == 0 {
+		return layers, false, nil
+	}
+	overlay, ok := profiles[profile]
+	if !ok {
+		return layers, false, nil
+	}
+	layers = append(layers, layer{doc: overlay, path: path, pointer: pointer(ProfilesKey, profile), positions: dataPositions})
+	return layers, true, nil
+}
+
+// internalData returns the JSON encoding of the internal object of the versioned config file data,
+// the unversioned config file data is returned as it is. It returns true if the data is versioned.
+func internalData(data []byte, scheme *Scheme) ([]byte, bool, error) {
+	var meta TypeMeta
+	if err := json.Unmarshal(stripComments(data), &meta); err != nil {
+		return nil, false, err
+	}
+	if len(meta.APIVersion) == 0 && len(meta.Kind) == 0 {
+		return data, false, nil
+	}
+	if scheme == nil {
+		return nil, true, fmt.Errorf("versioned config files are not supported, got apiVersion %q and kind %q", meta.APIVersion, meta.Kind)
+	}
+
+	obj, _, err := scheme.Decode(data)
+	if err != nil {
+		return nil, true, err
+	}
+	data, err = json.Marshal(obj)
+	return data, true, err
+}
+
+// decode decodes the config file data into the sections and the profile overlays, the numbers
+// are decoded as json.Number, so the integers which don't fit in a float64 keep their digits.
+func decode(data []byte) (document, map[string]document, error) {
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(stripComments(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	profiles := map[string]document{}
+	if rawProfiles, ok := raw[ProfilesKey]; ok {
+		delete(raw, ProfilesKey)
+		overlays, ok := rawProfiles.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%q is not an object", ProfilesKey)
+		}
+		for name, overlay := range overlays {
+			sections, ok := overlay.(map[string]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("profile %q is not an object", name)
+			}
+			doc, err := toDocument(sections)
+			if err != nil {
+				return nil, nil, fmt.Errorf("profile %q: %v", name, err)
+			}
+			profiles[name] = doc
+		}
+	}
+
+	doc, err := toDocument(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, profiles, nil
+}
+
+// toDocument checks that the sections are objects.
+func toDocument(raw map[string]interface{}) (document, error) {
+	doc := make(document, len(raw))
+	for name, section := range raw {
+		values, ok := section.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("section %q is not an object", name)
+		}
+		doc[name] = values
+	}
+	return doc, nil
+}
+
+// apply sets the flags of nfs from the merged config files, origins records the config
+// file and the position of each flag value. The flags which are not overridable are
+// skipped unless force is true.
+func apply(nfs *cliflag.NamedFlagSets, merged document, origins map[string]map[string]origin, force bool) error {
+	var errs []error
+	for _, sectionName := range sortedKeys(merged) {
+		fs := nfs.FlagSets[sectionName]
+		values := merged[sectionName]
+		for _, flagName := range sortedKeys(values) {
+			flag := fs.Lookup(flagName)
+			if !force && !overridable(flag) {
+				continue
+			}
+			at := origins[sectionName][flagName]
+			if err := setFlag(flag, values[flagName]); err != nil {
+				errs = append(errs, at.error("invalid value for flag %q in section %q: %v", flagName, sectionName, err))
+				continue
+			}
+			if flag.Annotations == nil {
+				flag.Annotations = map[string][]string{}
+			}
+			flag.Annotations[Annotation] = []string{at.path, strconv.Itoa(at.line)}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overridable returns true if the flag is set neither on the command line nor from an environment variable.
+func overridable(flag *pflag.Flag) bool {
+	if flag.Changed {
+		return false
+	}
+	_, fromEnv := cliflag.FlagSetFromEnv(flag)
+	return !fromEnv
+}
+
+// setFlag sets the flag from a decoded JSON value.
+func setFlag(flag *pflag.Flag, value interface{}) error {
+	switch v := value.(type) {
+	case []interface{}:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, err := scalarString(elem)
+			if err != nil {
+				return err
+			}
+			elems = append(elems, s)
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			return slice.Replace(elems)
+		}
+		for _, elem := range elems {
+			if err := flag.Value.Set(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		pairs, err := mapPairs(flag.Value, v)
+		if err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			// clear the default values
+			return flag.Value.Set("")
+		}
+		for _, pair := range pairs {
+			if err := flag.Value.Set(pair); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		s, err := scalarString(v)
+		if err != nil {
+			return err
+		}
+		if v, ok := flag.Value.(cliflag.CommandLineOnly); ok {
+			if err = v.CommandLineOnly(s); err != nil {
+				return err
+			}
+		}
+		return flag.Value.Set(s)
+	}
+}
+
+// mapPairs returns the key-value pairs of a decoded JSON object in the format of the map flag value.
+func mapPairs(value pflag.Value, m map[string]interface{}) ([]string, error) {
+	separator := "="
+	switch value.(type) {
+	case *cliflag.ColonSeparatedMultimapStringString:
+		separator = ":"
+	case *cliflag.LangleSeparatedMapStringString:
+		separator = "<"
+	}
+
+	pairs := make([]string, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		elems, ok := m[key].([]interface{})
+		if !ok || separator != ":" {
+			elems = []interface{}{m[key]}
+		}
+		for _, elem := range elems {
+			s, err := scalarString(elem)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, key+separator+s)
+		}
+	}
+	return pairs, nil
+}
+
+// scalarString returns the string of a decoded JSON string, number or boolean.
+func scalarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v, only strings, numbers and booleans are allowed here", v)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+type testFlags struct {
+	port     int
+	host     string
+	debug    bool
+	names    []string
+	labels   map[string]string
+	certKeys []cliflag.NamedCertKey
+}
+
+func newTestFlagSets(f *testFlags) *cliflag.NamedFlagSets {
+	nfs := &cliflag.NamedFlagSets{EnvPrefix: "TESTAPP"}
+	fs := nfs.FlagSet("secure serving")
+	fs.IntVar(&f.port, "secure-port", 443, "")
+	fs.StringVar(&f.host, "bind-address", "0.0.0.0", "")
+	fs.Var(cliflag.NewNamedCertKeyArray(&f.certKeys), "tls-sni-cert-key", "")
+	generic := nfs.FlagSet("generic")
+	generic.BoolVar(&f.debug, "debug", false, "")
+	generic.StringSliceVar(&f.names, "names", []string{"default"}, "")
+	generic.Var(cliflag.NewMapStringString(&f.labels), "labels", "")
+	return nfs
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustReadFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLoadFile(t *testing.T) {
+	var f testFlags
+	nfs := newTestFlagSets(&f)
+	path := writeConfig(t, `{
+		"secure serving": {
+			"secure-port": 8443,
+			"bind-address": "10.0.0.1",
+			"tls-sni-cert-key": ["foo.crt,foo.key:foo.com", "bar.crt,bar.key"]
+		},
+		"generic": {
+			"debug": true,
+			"names": ["a", "b"],
+			"labels": {"env": "prod", "app": "demo"}
+		}
+	}`)
+
+	if err := nfs.FlagSets["secure serving"].Parse([]string{"--bind-address=127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(nfs, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.port != 8443 {
+		t.Errorf("expect port 8443 but got %d", f.port)
+	}
+	if f.host != "127.0.0.1" {
+		t.Errorf("expect the command line value 127.0.0.1 but got %s", f.host)
+	}
+	if !f.debug {
+		t.Errorf("expect debug to be true")
+	}
+	if !reflect.DeepEqual(f.names, []string{"a", "b"}) {
+		t.Errorf("unexpected names %v", f.names)
+	}
+	if !reflect.DeepEqual(f.labels, map[string]string{"env": "prod", "app": "demo"}) {
+		t.Errorf("unexpected labels %v", f.labels)
+	}
+	expectCertKeys := []cliflag.NamedCertKey{
+		{CertFile: "foo.crt", KeyFile: "foo.key", Names: []string{"foo.com"}},
+		{CertFile: "bar.crt", KeyFile: "bar.key"},
+	}
+	if !reflect.DeepEqual(f.certKeys, expectCertKeys) {
+		t.Errorf("expect %v but got %v", expectCertKeys, f.certKeys)
+	}
+
+	portFlag := nfs.FlagSets["secure serving"].Lookup("secure-port")
+	if got, ok := FlagSetFromConfig(portFlag); !ok || got != path {
+		t.Errorf("expect the config annotation %s but got %q", path, got)
+	}
+	if portFlag.Changed {
+		t.Errorf("expect the flag set from the config file not to be changed")
+	}
+}
+
+func TestLoadFileSkipsEnv(t *testing.T) {
+	var f testFlags
+	nfs := newTestFlagSets(&f)
+	path := writeConfig(t, `{"secure serving": {"secure-port": 8443}}`)
+
+	t.Setenv("TESTAPP_SECURE_PORT", "9443")
+	if err := nfs.SetFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(nfs, path); err != nil {
+		t.Fatal(err)
+	}
+	if f.port != 9443 {
+		t.Errorf("expect the env value 9443 but got %d", f.port)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	cases := []struct {
+		desc    string
+		content string
+		expect  []string
+	}{
+		{
+			desc:    "invalid json",
+			content: `{"generic": `,
+			expect:  []string{"unable to decode config file"},
+		},
+		{
+			desc:    "section not an object",
+			content: `{"generic": true}`,
+			expect:  []string{`section "generic" is not an object`},
+		},
+		{
+			desc:    "unknown keys",
+			content: `{"unknown": {}, "generic": {"unknown-flag": 1, "debug": true}}`,
+			expect: []string{
+				`config.json:1:2: /unknown: unknown section "unknown"`,
+				`config.json:1:29: /generic/unknown-flag: unknown flag "unknown-flag" in section "generic"`,
+			},
+		},
+		{
+			desc:    "wrong types",
+			content: `{"generic": {"debug": "yes", "names": ["a", 1]}, "secure serving": {"secure-port": "abc"}}`,
+			expect: []string{
+				`config.json:1:14: /generic/debug: invalid value for flag "debug" in section "generic": expect boolean or null, got string`,
+				`config.json:1:45: /generic/names/1: invalid value for flag "names" in section "generic": expect string, got integer`,
+				`config.json:1:69: /secure serving/secure-port: invalid value for flag "secure-port" in section "secure serving": expect integer or null, got string`,
+			},
+		},
+		{
+			desc:    "malformed map pairs",
+			content: "{\n\"generic\": {\"labels\": {\"a=b\": \"c\", \"d\": {\"e\": \"f\"}}}}",
+			expect: []string{
+				`config.json:2:24: /generic/labels/a=b: invalid value for flag "labels" in section "generic": malformed map pair, key "a=b" doesn't match "^[^,=]*$"`,
+				`config.json:2:36: /generic/labels/d: invalid value for flag "labels" in section "generic": expect string or null, got object`,
+			},
+		},
+		{
+			desc:    "invalid values",
+			content: `{"secure serving": {"tls-sni-cert-key": ["foo.crt"], "bind-address": "0.0.0.0"}, "generic": {"labels": "a"}}`,
+			expect: []string{
+				`config.json:1:21: /secure serving/tls-sni-cert-key: invalid value for flag "tls-sni-cert-key" in section "secure serving"`,
+				`config.json:1:94: /generic/labels: invalid value for flag "labels" in section "generic"`,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var f testFlags
+			path := writeConfig(t, c.content)
+			err := LoadFile(newTestFlagSets(&f), path)
+			if err == nil {
+				t.Fatalf("expect errors %v", c.expect)
+			}
+			for _, expect := range c.expect {
+				if !strings.Contains(err.Error(), expect) {
+					t.Errorf("expect error %q in %q", expect, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsLoad(t *testing.T) {
+	var f testFlags
+	nfs := newTestFlagSets(&f)
+	o := NewOptions()
+	o.AddFlags(nfs.FlagSet("global"))
+
+	if err := o.Load(nfs); err != nil {
+		t.Fatalf("expect no error without config file, got %v", err)
+	}
+
+	path := writeConfig(t, `{"generic": {"debug": true}}`)
+	if err := nfs.FlagSets["global"].Parse([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Load(nfs); err != nil {
+		t.Fatal(err)
+	}
+	if !f.debug {
+		t.Errorf("expect debug to be true")
+	}
+}
+
+func TestLoadFileLargeIntegers(t *testing.T) {
+	var (
+		id    int64
+		size  uint64
+		ids   []int64
+		ratio float64
+	)
+	nfs := &cliflag.NamedFlagSets{}
+	fs := nfs.FlagSet("generic")
+	fs.Int64Var(&id, "id", 0, "")
+	fs.Uint64Var(&size, "size", 0, "")
+	fs.Int64SliceVar(&ids, "ids", nil, "")
+	fs.Float64Var(&ratio, "ratio", 0, "")
+	path := writeConfig(t, `{"generic": {"id": 9007199254740993, "size": 18446744073709551615, "ids": [-9007199254740993], "ratio": 1e-3}}`)
+	if err := LoadFile(nfs, path); err != nil {
+		t.Fatal(err)
+	}
+	if id != 9007199254740993 || size != 18446744073709551615 || !reflect.DeepEqual(ids, []int64{-9007199254740993}) || ratio != 0.001 {
+		t.Errorf("expect the exact values but got %d, %d, %v and %v", id, size, ids, ratio)
+	}
+}
+
+func TestLoadFileCommandLineOnly(t *testing.T) {
+	var ciphers []string
+	v := cliflag.NewTLSCipherSuitesValue(&ciphers)
+	v.Exit = func(int) { t.Fatal("expect no exit for the config file") }
+	nfs := &cliflag.NamedFlagSets{}
+	nfs.FlagSet("secure serving").Var(v, "tls-cipher-suites", "")
+	path := writeConfig(t, `{"secure serving": {"tls-cipher-suites": "help"}}`)
+
+	err := LoadFile(nfs, path)
+	if err == nil || !strings.Contains(err.Error(), `"help" is only allowed on the command line`) {
+		t.Errorf("expect the help value to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+)
+
+// merge deep merges the config file layer into dst, see LoadFiles for the rules.
+// origins records the config file and the line of each merged flag value.
+func merge(dst document, origins map[string]map[string]origin, l layer) []error {
+	var errs []error
+	for _, sectionName := range sortedKeys(l.doc) {
+		if dst[sectionName] == nil {
+			dst[sectionName] = map[string]interface{}{}
+			origins[sectionName] = map[string]origin{}
+		}
+		values := dst[sectionName]
+		for _, key := range sortedKeys(l.doc[sectionName]) {
+			value := l.doc[sectionName][key]
+			at := l.origin(sectionName, key)
+			flagName := strings.TrimSuffix(key, AppendSuffix)
+
+			switch {
+			case flagName != key:
+				elems, ok := value.([]interface{})
+				if !ok {
+					errs = append(errs, at.error("flag %q in section %q must be an array to be appended", key, sectionName))
+					continue
+				}
+				earlier, _ := values[flagName].([]interface{})
+				values[flagName] = append(append([]interface{}{}, earlier...), elems...)
+			case value == nil:
+				delete(values, flagName)
+				delete(origins[sectionName], flagName)
+				continue
+			default:
+				values[flagName] = mergeValue(values[flagName], value)
+			}
+			origins[sectionName][flagName] = at
+		}
+	}
+	return errs
+}
+
+// mergeValue merges the later value into the earlier value, objects are merged by key
+// and null removes the key, other values replace the earlier values.
+// The nulls of a later object are removed even if there is no earlier object.
+func mergeValue(earlier, later interface{}) interface{} {
+	laterMap, ok := later.(map[string]interface{})
+	if !ok {
+		return later
+	}
+
+	earlierMap, _ := earlier.(map[string]interface{})
+	merged := make(map[string]interface{}, len(earlierMap)+len(laterMap))
+	for key, value := range earlierMap {
+		merged[key] = value
+	}
+	for key, value := range laterMap {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
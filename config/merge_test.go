@@ -0,0 +1,180 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+type layeredFlags struct {
+	testFlags
+	multimap map[string][]string
+	langle   map[string]string
+}
+
+func newLayeredFlagSets(f *layeredFlags) *cliflag.NamedFlagSets {
+	nfs := newTestFlagSets(&f.testFlags)
+	misc := nfs.FlagSet("misc")
+	misc.Var(cliflag.NewColonSeparatedMultimapStringString(&f.multimap), "multimap", "")
+	misc.Var(cliflag.NewLangleSeparatedMapStringString(&f.langle), "langle", "")
+	return nfs
+}
+
+func writeConfigs(t *testing.T, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, 0, len(contents))
+	for i, content := range contents {
+		path := filepath.Join(dir, string(rune('a'+i))+".json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestLoadFiles(t *testing.T) {
+	paths := writeConfigs(t, `{
+		"secure serving": {"secure-port": 8443, "bind-address": "10.0.0.1"},
+		"generic": {
+			"names": ["a", "b"],
+			"labels": {"app": "demo", "env": "dev", "team": "x"}
+		},
+		"misc": {
+			"multimap": {"k": ["v1", "v2"], "l": "v3"},
+			"langle": {"a": "b"}
+		}
+	}`, `{
+		// the overlay of the production environment
+		"secure serving": {"bind-address": null},
+		"generic": {
+			"names+": ["c"],
+			"labels": {"env": "prod", "team": null}
+		},
+		"misc": {
+			"multimap": {"k": ["v4"]}
+		},
+		"profiles": {
+			"debug": {"generic": {"debug": true, "names+": ["d"]}}
+		}
+	}`)
+
+	var f layeredFlags
+	nfs := newLayeredFlagSets(&f)
+	if err := LoadFiles(nfs, paths, "debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.port != 8443 {
+		t.Errorf("expect port 8443 but got %d", f.port)
+	}
+	if f.host != "0.0.0.0" {
+		t.Errorf("expect the default bind address after null but got %s", f.host)
+	}
+	if !f.debug {
+		t.Errorf("expect debug from the profile")
+	}
+	if expect := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(f.names, expect) {
+		t.Errorf("expect names %v but got %v", expect, f.names)
+	}
+	if expect := map[string]string{"app": "demo", "env": "prod"}; !reflect.DeepEqual(f.labels, expect) {
+		t.Errorf("expect labels %v but got %v", expect, f.labels)
+	}
+	if expect := map[string][]string{"k": {"v4"}, "l": {"v3"}}; !reflect.DeepEqual(f.multimap, expect) {
+		t.Errorf("expect multimap %v but got %v", expect, f.multimap)
+	}
+	if expect := map[string]string{"a": "b"}; !reflect.DeepEqual(f.langle, expect) {
+		t.Errorf("expect langle %v but got %v", expect, f.langle)
+	}
+
+	origins := map[string]string{"secure-port": paths[0], "names": paths[1], "debug": paths[1]}
+	for name, expect := range origins {
+		flag := nfs.FlagSets["secure serving"].Lookup(name)
+		if flag == nil {
+			flag = nfs.FlagSets["generic"].Lookup(name)
+		}
+		if got, _ := FlagSetFromConfig(flag); got != expect {
+			t.Errorf("expect flag %s from %s but got %s", name, expect, got)
+		}
+	}
+	sources := map[string]cliflag.ValueSource{
+		"secure-port": {Type: cliflag.SourceConfigFile, Location: paths[0] + ":2"},
+		"debug":       {Type: cliflag.SourceConfigFile, Location: paths[1] + ":12"},
+		"names":       {Type: cliflag.SourceConfigFile, Location: paths[1] + ":12"},
+	}
+	for name, expect := range sources {
+		flag := nfs.FlagSets["secure serving"].Lookup(name)
+		if flag == nil {
+			flag = nfs.FlagSets["generic"].Lookup(name)
+		}
+		if got := cliflag.FlagValueSource(flag); got != expect {
+			t.Errorf("expect flag %s from %v but got %v", name, expect, got)
+		}
+	}
+	if _, ok := FlagSetFromConfig(nfs.FlagSets["secure serving"].Lookup("bind-address")); ok {
+		t.Errorf("expect no config annotation for the removed flag value")
+	}
+}
+
+func TestLoadFilesErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		contents []string
+		profile  string
+		expect   []string
+	}{
+		{
+			desc:     "profile not found",
+			contents: []string{`{"generic": {}}`, `{"profiles": {"debug": {}}}`},
+			profile:  "prod",
+			expect:   []string{`profile "prod" not found in config files`},
+		},
+		{
+			desc:     "append without array",
+			contents: []string{`{"generic": {"names+": "c"}}`},
+			expect:   []string{`a.json:1:14: /generic/names+: invalid value for flag "names+" in section "generic": expect array, got string`},
+		},
+		{
+			desc:     "unknown flag in profile",
+			contents: []string{`{"profiles": {"debug": {"generic": {"unknown": 1}}}}`},
+			profile:  "debug",
+			expect:   []string{`unknown flag "unknown" in section "generic"`},
+		},
+		{
+			desc:     "invalid profiles",
+			contents: []string{`{"profiles": {"debug": []}}`},
+			expect:   []string{`profile "debug" is not an object`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var f layeredFlags
+			err := LoadFiles(newLayeredFlagSets(&f), writeConfigs(t, c.contents...), c.profile)
+			if err == nil {
+				t.Fatalf("expect errors %v", c.expect)
+			}
+			for _, expect := range c.expect {
+				if !strings.Contains(err.Error(), expect) {
+					t.Errorf("expect error %q in %q", expect, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	o := NewOptions()
+	o.Profile = "debug"
+	if errs := o.Validate(); len(errs) != 1 {
+		t.Fatalf("expect an error for --profile without --config, got %v", errs)
+	}
+	o.Paths = []string{"config.json"}
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Fatalf("expect no errors, got %v", errs)
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shipengqi/component-base/json"
+)
+
+// position is the line and the column of a value in a config file, both start at 1.
+type position struct {
+	line   int
+	column int
+}
+
+// pointerEscaper escapes the reference tokens of JSON pointers (RFC 6901).
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointer returns the JSON pointer of the given reference tokens, e.g. "/secure serving/secure-port".
+func pointer(tokens ...string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString("/")
+		b.WriteString(pointerEscaper.Replace(token))
+	}
+	return b.String()
+}
+
+// positionScanner finds the positions of the values of a JSON document.
+type positionScanner struct {
+	data       []byte
+	offset     int
+	lineStarts []int
+	positions  map[string]position
+}
+
+// positions returns the positions of the object members and the array elements of the valid
+// JSON data, by their JSON pointers. The position of an object member is the position of its key.
+func positions(data []byte) map[string]position {
+	s := &positionScanner{
+		data:       stripComments(data),
+		lineStarts: []int{0},
+		positions:  map[string]position{},
+	}
+	for i, c := range data {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+	s.value("")
+	return s.positions
+}
+
+// position returns the position of the offset.
+func (s *positionScanner) position(offset int) position {
+	line := sort.Search(len(s.lineStarts), func(i int) bool { return s.lineStarts[i] > offset })
+	return position{line: line, column: offset - s.lineStarts[line-1] + 1}
+}
+
+func (s *positionScanner) skipSpace() {
+	for s.offset < len(s.data) {
+		switch s.data[s.offset] {
+		case ' ', '\t', '\r', '\n':
+			s.offset++
+		default:
+			return
+		}
+	}
+}
+
+// value scans the value at the current offset, whose JSON pointer is ptr.
+func (s *positionScanner) value(ptr string) {
+	s.skipSpace()
+	if s.offset >= len(s.data) {
+		return
+	}
+	switch s.data[s.offset] {
+	case '{':
+		s.object(ptr)
+	case '[':
+		s.array(ptr)
+	case '"':
+		s.str()
+	default:
+		// numbers, true, false and null
+		for s.offset < len(s.data) && !strings.ContainsRune(" \t\r\n,]}", rune(s.data[s.offset])) {
+			s.offset++
+		}
+	}
+}
+
+func (s *positionScanner) object(ptr string) {
+	s.offset++ // {
+	for {
+		s.skipSpace()
+		if s.offset >= len(s.data) || s.data[s.offset] != '"' {
+			// } of an empty object, or invalid data
+			s.offset++
+			return
+		}
+		keyOffset := s.offset
+		var key string
+		if err := json.Unmarshal(s.str(), &key); err != nil {
+			return
+		}
+		memberPtr := ptr + pointer(key)
+		s.positions[memberPtr] = s.position(keyOffset)
+
+		s.skipSpace()
+		s.offset++ // :
+		s.value(memberPtr)
+		s.skipSpace()
+		if s.offset >= len(s.data) || s.data[s.offset] != ',' {
+			s.offset++ // }
+			return
+		}
+		s.offset++ // ,
+	}
+}
+
+func (s *positionScanner) array(ptr string) {
+	s.offset++ // [
+	for i := 0; ; i++ {
+		s.skipSpace()
+		if s.offset >= len(s.data) || s.data[s.offset] == ']' {
+			s.offset++
+			return
+		}
+		elemPtr := ptr + "/" + strconv.Itoa(i)
+		s.positions[elemPtr] = s.position(s.offset)
+		s.value(elemPtr)
+		s.skipSpace()
+		if s.offset >= len(s.data) || s.data[s.offset] != ',' {
+			s.offset++ // ]
+			return
+		}
+		s.offset++ // ,
+	}
+}
+
+// str scans the string at the current offset and returns it with the quotes.
+func (s *positionScanner) str() []byte {
+	start := s.offset
+	s.offset++ // opening quote
+	for s.offset < len(s.data) {
+		switch s.data[s.offset] {
+		case '\\':
+			s.offset += 2
+			continue
+		case '"':
+			s.offset++
+			return s.data[start:s.offset]
+		}
+		s.offset++
+	}
+	return s.data[start:]
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	data := []byte(`{
+  // a comment with "quotes" and {braces}
+  "secure serving": {"secure-port": 443, "a/b~c": "x\"y"},
+  "generic": {
+    "names": ["a", {"b": null}],
+    "empty": {}, "list": []
+  }
+}`)
+	expect := map[string]position{
+		"/secure serving":             {line: 3, column: 3},
+		"/secure serving/secure-port": {line: 3, column: 22},
+		"/secure serving/a~1b~0c":     {line: 3, column: 42},
+		"/generic":                    {line: 4, column: 3},
+		"/generic/names":              {line: 5, column: 5},
+		"/generic/names/0":            {line: 5, column: 15},
+		"/generic/names/1":            {line: 5, column: 20},
+		"/generic/names/1/b":          {line: 5, column: 21},
+		"/generic/empty":              {line: 6, column: 5},
+		"/generic/list":               {line: 6, column: 18},
+	}
+	if got := positions(data); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %v but got %v", expect, got)
+	}
+}
+
+func TestPointer(t *testing.T) {
+	if got := pointer("profiles", "a/b", "c~d"); got != "/profiles/a~1b/c~0d" {
+		t.Fatalf("unexpected pointer %q", got)
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shipengqi/component-base/json"
+)
+
+// InternalVersion is the version of the internal types, which the versioned types are converted to.
+const InternalVersion = "__internal"
+
+// TypeMeta describes the version and the kind of a versioned config document,
+// the versioned types embed it.
+type TypeMeta struct {
+	// APIVersion is the version of the config document, e.g. "example.io/v1beta1".
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind is the kind of the config document, e.g. "ServerConfiguration".
+	Kind string `json:"kind,omitempty"`
+}
+
+// NewFunc returns a new object of a registered type.
+type NewFunc func() interface{}
+
+// DefaultingFunc sets the default values of an object of a registered type.
+type DefaultingFunc func(obj interface{})
+
+// ConversionFunc converts the object in of a version into the object out of the next version.
+type ConversionFunc func(in, out interface{}) error
+
+// kindInfo stores the registrations of a kind.
+type kindInfo struct {
+	newFuncs    map[string]NewFunc
+	defaulters  map[string]DefaultingFunc
+	conversions map[string]conversion
+}
+
+// conversion converts a version into the next version.
+type conversion struct {
+	to string
+	fn ConversionFunc
+}
+
+// Scheme stores the versioned and internal types of the config kinds, the defaulting functions
+// of each version, and the conversion functions from each version to the next one, e.g.
+// v1alpha1 to v1beta1, v1beta1 to v1 and v1 to InternalVersion.
+// The registration is expected to happen at init time, a Scheme must not be changed
+// while it is used for decoding.
+type Scheme struct {
+	kinds map[string]*kindInfo
+}
+
+// NewScheme creates an empty Scheme.
+func NewScheme() *Scheme {
+	return &Scheme{kinds: map[string]*kindInfo{}}
+}
+
+// AddKnownType registers the type of apiVersion and kind, newFunc returns a new object of the type.
+// The versioned types embed TypeMeta, use InternalVersion as apiVersion to register the internal type.
+// It panics if the type is already registered.
+func (s *Scheme) AddKnownType(apiVersion, kind string, newFunc NewFunc) {
+	info := s.kind(kind)
+	if _, ok := info.newFuncs[apiVersion]; ok {
+		panic(fmt.Sprintf("kind %q of version %q is already registered", kind, apiVersion))
+	}
+	info.newFuncs[apiVersion] = newFunc
+}
+
+// AddDefaultingFunc registers the function which sets the default values of the type of apiVersion and kind.
+// The defaults are set after the document is decoded into the version, or converted into it.
+func (s *Scheme) AddDefaultingFunc(apiVersion, kind string, fn DefaultingFunc) {
+	s.kind(kind).defaulters[apiVersion] = fn
+}
+
+// AddConversionFunc registers the function which converts the type of fromVersion and kind into
+// the type of toVersion and kind. Each version can be converted into one next version only,
+// and the chain of the conversions must end with InternalVersion.
+// It panics if a conversion from fromVersion is already registered.
+func (s *Scheme) AddConversionFunc(kind, fromVersion, toVersion string, fn ConversionFunc) {
+	info := s.kind(kind)
+	if c, ok := info.conversions[fromVersion]; ok {
+		panic(fmt.Sprintf("conversion of kind %q from version %q to %q is already registered", kind, fromVersion, c.to))
+	}
+	info.conversions[fromVersion] = conversion{to: toVersion, fn: fn}
+}
+
+// Recognizes returns true if the scheme has the type of apiVersion and kind registered.
+func (s *Scheme) Recognizes(apiVersion, kind string) bool {
+	info, ok := s.kinds[kind]
+	if !ok {
+		return false
+	}
+	_, ok = info.newFuncs[apiVersion]
+	return ok
+}
+
+// Decode decodes the versioned config document data, sets the defaults of its version
+// and converts it into the internal type. Unknown fields are rejected, line comments
+// starting with "//" are allowed. It returns the internal object and the version and
+// kind of the document.
+func (s *Scheme) Decode(data []byte) (interface{}, TypeMeta, error) {
+	data = stripComments(data)
+	var meta TypeMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, meta, err
+	}
+	if len(meta.APIVersion) == 0 || len(meta.Kind) == 0 {
+		return nil, meta, fmt.Errorf("apiVersion and kind are required, got apiVersion %q and kind %q", meta.APIVersion, meta.Kind)
+	}
+
+	info, ok := s.kinds[meta.Kind]
+	if !ok {
+		return nil, meta, fmt.Errorf("unknown kind %q, known kinds: %s", meta.Kind, strings.Join(sortedKeys(s.kinds), ", "))
+	}
+	newFunc, ok := info.newFuncs[meta.APIVersion]
+	if !ok || meta.APIVersion == InternalVersion {
+		return nil, meta, fmt.Errorf("unknown apiVersion %q for kind %q, supported apiVersions: %s",
+			meta.APIVersion, meta.Kind, strings.Join(info.versions(), ", "))
+	}
+
+	obj := newFunc()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return nil, meta, fmt.Errorf("unable to decode %s %s: %v", meta.Kind, meta.APIVersion, err)
+	}
+	if defaulter, ok := info.defaulters[meta.APIVersion]; ok {
+		defaulter(obj)
+	}
+
+	internal, err := s.convert(info, meta, obj)
+	if err != nil {
+		return nil, meta, err
+	}
+	return internal, meta, nil
+}
+
+// convert converts the obj of the version and kind of meta into the internal type,
+// setting the defaults of each version on the way.
+func (s *Scheme) convert(info *kindInfo, meta TypeMeta, obj interface{}) (interface{}, error) {
+	version := meta.APIVersion
+	// each step moves to another version, so a chain longer than the versions has a loop
+	for steps := 0; version != InternalVersion; steps++ {
+		c, ok := info.conversions[version]
+		if !ok {
+			return nil, fmt.Errorf("no conversion from apiVersion %q of kind %q", version, meta.Kind)
+		}
+		newFunc, ok := info.newFuncs[c.to]
+		if !ok || steps >= len(info.newFuncs) {
+			return nil, fmt.Errorf("invalid conversion from apiVersion %q to %q of kind %q", version, c.to, meta.Kind)
+		}
+		out := newFunc()
+		if err := c.fn(obj, out); err != nil {
+			return nil, fmt.Errorf("unable to convert %s from %s to %s: %v", meta.Kind, version, c.to, err)
+		}
+		if defaulter, ok := info.defaulters[c.to]; ok {
+			defaulter(out)
+		}
+		obj, version = out, c.to
+	}
+	return obj, nil
+}
+
+func (s *Scheme) kind(kind string) *kindInfo {
+	info, ok := s.kinds[kind]
+	if !ok {
+		info = &kindInfo{
+			newFuncs:    map[string]NewFunc{},
+			defaulters:  map[string]DefaultingFunc{},
+			conversions: map[string]conversion{},
+		}
+		s.kinds[kind] = info
+	}
+	return info
+}
+
+// versions returns the sorted versions of the kind, without InternalVersion.
+func (k *kindInfo) versions() []string {
+	versions := make([]string, 0, len(k.newFuncs))
+	for version := range k.newFuncs {
+		if version != InternalVersion {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testKind     = "TestConfiguration"
+	testV1alpha1 = "test.io/v1alpha1"
+	testV1beta1  = "test.io/v1beta1"
+	testV1       = "test.io/v1"
+)
+
+// v1alpha1 has the port and the address in one field
+type testConfigV1alpha1 struct {
+	TypeMeta
+	Listen string `json:"listen"`
+}
+
+// v1beta1 splits the listen address
+type testConfigV1beta1 struct {
+	TypeMeta
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+}
+
+// v1 adds the debug field
+type testConfigV1 struct {
+	TypeMeta
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Debug   *bool  `json:"debug"`
+}
+
+type testConfigSecureServing struct {
+	BindAddress string `json:"bind-address,omitempty"`
+	SecurePort  int    `json:"secure-port,omitempty"`
+}
+
+type testConfigGeneric struct {
+	Debug bool `json:"debug"`
+}
+
+type testConfig struct {
+	SecureServing testConfigSecureServing `json:"secure serving"`
+	Generic       testConfigGeneric       `json:"generic"`
+}
+
+func newTestScheme() *Scheme {
+	scheme := NewScheme()
+	scheme.AddKnownType(testV1alpha1, testKind, func() interface{} { return &testConfigV1alpha1{} })
+	scheme.AddKnownType(testV1beta1, testKind, func() interface{} { return &testConfigV1beta1{} })
+	scheme.AddKnownType(testV1, testKind, func() interface{} { return &testConfigV1{} })
+	scheme.AddKnownType(InternalVersion, testKind, func() interface{} { return &testConfig{} })
+
+	scheme.AddDefaultingFunc(testV1alpha1, testKind, func(obj interface{}) {
+		if c := obj.(*testConfigV1alpha1); len(c.Listen) == 0 {
+			c.Listen = "127.0.0.1:8080"
+		}
+	})
+	scheme.AddDefaultingFunc(testV1, testKind, func(obj interface{}) {
+		if c := obj.(*testConfigV1); c.Debug == nil {
+			debug := true
+			c.Debug = &debug
+		}
+	})
+
+	scheme.AddConversionFunc(testKind, testV1alpha1, testV1beta1, func(in, out interface{}) error {
+		listen := strings.Split(in.(*testConfigV1alpha1).Listen, ":")
+		if len(listen) != 2 {
+			return errors.New("listen must be address:port")
+		}
+		c := out.(*testConfigV1beta1)
+		c.Address = listen[0]
+		c.Port = len(listen[1]) * 1000
+		return nil
+	})
+	scheme.AddConversionFunc(testKind, testV1beta1, testV1, func(in, out interface{}) error {
+		c := out.(*testConfigV1)
+		c.Address, c.Port = in.(*testConfigV1beta1).Address, in.(*testConfigV1beta1).Port
+		return nil
+	})
+	scheme.AddConversionFunc(testKind, testV1, InternalVersion, func(in, out interface{}) error {
+		v1, c := in.(*testConfigV1), out.(*testConfig)
+		c.SecureServing = testConfigSecureServing{BindAddress: v1.Address, SecurePort: v1.Port}
+		c.Generic.Debug = *v1.Debug
+		return nil
+	})
+	return scheme
+}
+
+func TestSchemeDecode(t *testing.T) {
+	cases := []struct {
+		desc   string
+		data   string
+		expect *testConfig
+		err    string
+	}{
+		{
+			desc: "v1",
+			data: `{"apiVersion": "test.io/v1", "kind": "TestConfiguration", "address": "10.0.0.1", "port": 8443, "debug": false}`,
+			expect: &testConfig{
+				SecureServing: testConfigSecureServing{BindAddress: "10.0.0.1", SecurePort: 8443},
+			},
+		},
+		{
+			desc: "v1beta1 with v1 defaults",
+			data: `{"apiVersion": "test.io/v1beta1", "kind": "TestConfiguration", "address": "10.0.0.1", "port": 8443}`,
+			expect: &testConfig{
+				SecureServing: testConfigSecureServing{BindAddress: "10.0.0.1", SecurePort: 8443},
+				Generic:       testConfigGeneric{Debug: true},
+			},
+		},
+		{
+			desc: "v1alpha1 with v1alpha1 defaults",
+			data: `{"apiVersion": "test.io/v1alpha1", "kind": "TestConfiguration"} // comment`,
+			expect: &testConfig{
+				SecureServing: testConfigSecureServing{BindAddress: "127.0.0.1", SecurePort: 4000},
+				Generic:       testConfigGeneric{Debug: true},
+			},
+		},
+		{
+			desc: "missing kind",
+			data: `{"apiVersion": "test.io/v1"}`,
+			err:  `apiVersion and kind are required, got apiVersion "test.io/v1" and kind ""`,
+		},
+		{
+			desc: "unknown kind",
+			data: `{"apiVersion": "test.io/v1", "kind": "Unknown"}`,
+			err:  `unknown kind "Unknown", known kinds: TestConfiguration`,
+		},
+		{
+			desc: "unknown version",
+			data: `{"apiVersion": "test.io/v2", "kind": "TestConfiguration"}`,
+			err: `unknown apiVersion "test.io/v2" for kind "TestConfiguration", ` +
+				`supported apiVersions: test.io/v1, test.io/v1alpha1, test.io/v1beta1`,
+		},
+		{
+			desc: "internal version",
+			data: `{"apiVersion": "__internal", "kind": "TestConfiguration"}`,
+			err:  `unknown apiVersion "__internal"`,
+		},
+		{
+			desc: "unknown field",
+			data: `{"apiVersion": "test.io/v1beta1", "kind": "TestConfiguration", "listen": ":80"}`,
+			err:  "unable to decode TestConfiguration test.io/v1beta1",
+		},
+		{
+			desc: "conversion error",
+			data: `{"apiVersion": "test.io/v1alpha1", "kind": "TestConfiguration", "listen": "80"}`,
+			err:  "unable to convert TestConfiguration from test.io/v1alpha1 to test.io/v1beta1: listen must be address:port",
+		},
+	}
+	scheme := newTestScheme()
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			obj, _, err := scheme.Decode([]byte(c.data))
+			if len(c.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expect error %q but got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(obj, c.expect) {
+				t.Fatalf("expect %+v but got %+v", c.expect, obj)
+			}
+		})
+	}
+}
+
+func TestSchemeMissingConversion(t *testing.T) {
+	scheme := NewScheme()
+	scheme.AddKnownType(testV1, testKind, func() interface{} { return &testConfigV1{} })
+	_, _, err := scheme.Decode([]byte(`{"apiVersion": "test.io/v1", "kind": "TestConfiguration"}`))
+	if err == nil || !strings.Contains(err.Error(), `no conversion from apiVersion "test.io/v1"`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLoadFilesWithScheme(t *testing.T) {
+	paths := writeConfigs(t,
+		`{"apiVersion": "test.io/v1beta1", "kind": "TestConfiguration", "address": "10.0.0.1", "port": 8443}`,
+		`{"generic": {"names": ["a"]}}`,
+	)
+
+	var f testFlags
+	nfs := newTestFlagSets(&f)
+	if err := LoadFilesWithScheme(nfs, newTestScheme(), paths, ""); err != nil {
+		t.Fatal(err)
+	}
+	if f.host != "10.0.0.1" || f.port != 8443 || !f.debug || !reflect.DeepEqual(f.names, []string{"a"}) {
+		t.Fatalf("unexpected flag values %+v", f)
+	}
+
+	err := LoadFiles(newTestFlagSets(&f), paths, "")
+	if err == nil || !strings.Contains(err.Error(), "versioned config files are not supported") {
+		t.Fatalf("expect an error without scheme, got %v", err)
+	}
+}
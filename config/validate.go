@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// ValidateFlagName is the name of the flag which validates the config files and exits.
+const ValidateFlagName = "validate-config"
+
+// ValidationError is a violation of a config file.
+type ValidationError struct {
+	// Path is the path of the config file.
+	Path string
+	// Pointer is the JSON pointer of the invalid value in the config file, or in the
+	// internal object of a versioned config file.
+	Pointer string
+	// Line and Column are the position of the invalid value in the config file, both start at 1.
+	// They are 0 if the position is unknown, e.g. in versioned config files.
+	Line   int
+	Column int
+	// Message describes the violation.
+	Message string
+}
+
+// Error returns the path, the position if it is known, the JSON pointer and the message,
+// e.g. "config.json:3:5: /generic/debug: invalid value ...".
+func (e *ValidationError) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", e.Path, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, e.Pointer, e.Message)
+}
+
+// validator validates a decoded config file against the JSON schema of the flags.
+type validator struct {
+	schema    *jsonSchema
+	path      string
+	positions map[string]position
+	errs      []error
+}
+
+// validateData returns the violations of the decoded config file data at path, with the positions of its values.
+func validateData(nfs *cliflag.NamedFlagSets, path string, data map[string]interface{}, dataPositions map[string]position) []error {
+	v := &validator{schema: schemaOf(nfs), path: path, positions: dataPositions}
+	v.document(data, "")
+	if profiles, ok := data[ProfilesKey].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(profiles) {
+			if overlay, ok := profiles[name].(map[string]interface{}); ok {
+				v.document(overlay, pointer(ProfilesKey, name))
+			}
+		}
+	}
+	return v.errs
+}
+
+// report records a violation of the value at ptr.
+func (v *validator) report(ptr string, format string, args ...interface{}) {
+	pos := v.positions[ptr]
+	v.errs = append(v.errs, &ValidationError{
+		Path:    v.path,
+		Pointer: ptr,
+		Line:    pos.line,
+		Column:  pos.column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// document validates the sections of a config file or a profile overlay at ptr.
+func (v *validator) document(doc map[string]interface{}, ptr string) {
+	for _, sectionName := range sortedKeys(doc) {
+		if len(ptr) == 0 && sectionName == ProfilesKey {
+			continue
+		}
+		sectionPtr := ptr + pointer(sectionName)
+		section, ok := v.schema.Defs[sectionName]
+		if !ok {
+			v.report(sectionPtr, "unknown section %q", sectionName)
+			continue
+		}
+		values, _ := doc[sectionName].(map[string]interface{})
+		for _, key := range sortedKeys(values) {
+			s, ok := section.Properties[key]
+			if !ok {
+				v.report(sectionPtr+pointer(key), "unknown flag %q in section %q", key, sectionName)
+				continue
+			}
+			prefix := fmt.Sprintf("invalid value for flag %q in section %q", key, sectionName)
+			v.value(s, values[key], sectionPtr+pointer(key), prefix)
+		}
+	}
+}
+
+// value validates the value at ptr against the schema s, prefix is the prefix of the messages.
+func (v *validator) value(s *jsonSchema, value interface{}, ptr string, prefix string) {
+	typ := jsonType(value)
+	if !s.Type.allows(typ) {
+		v.report(ptr, "%s: expect %s, got %s", prefix, strings.Join(s.Type, " or "), typ)
+		return
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		possible := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			if e != nil {
+				possible = append(possible, fmt.Sprint(e))
+			}
+		}
+		v.report(ptr, "%s: %v is not one of the possible values: %s", prefix, value, strings.Join(possible, ", "))
+	}
+	if f, ok := value.(float64); ok && s.Minimum != nil && f < float64(*s.Minimum) {
+		v.report(ptr, "%s: %v is less than the minimum %d", prefix, value, *s.Minimum)
+	}
+
+	switch val := value.(type) {
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, elem := range val {
+			v.value(s.Items, elem, fmt.Sprintf("%s/%d", ptr, i), prefix)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(val) {
+			keyPtr := ptr + pointer(key)
+			if s.PropertyNames != nil && len(s.PropertyNames.Pattern) > 0 {
+				if matched, _ := regexp.MatchString(s.PropertyNames.Pattern, key); !matched {
+					v.report(keyPtr, "%s: malformed map pair, key %q doesn't match %q", prefix, key, s.PropertyNames.Pattern)
+					continue
+				}
+			}
+			if additional, ok := s.AdditionalProperties.(*jsonSchema); ok {
+				v.value(additional, val[key], keyPtr, prefix)
+			}
+		}
+	}
+}
+
+// allows returns true if the JSON type is one of the types, integers are numbers as well.
+func (t schemaTypes) allows(typ string) bool {
+	for _, allowed := range t {
+		if allowed == typ || (allowed == "number" && typ == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON type of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
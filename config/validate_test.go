@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	cases := []struct {
+		desc   string
+		err    *ValidationError
+		expect string
+	}{
+		{"with position", &ValidationError{Path: "a.json", Pointer: "/generic/debug", Line: 3, Column: 5, Message: "invalid"},
+			"a.json:3:5: /generic/debug: invalid"},
+		{"without position", &ValidationError{Path: "a.json", Pointer: "/generic/debug", Message: "invalid"},
+			"a.json: /generic/debug: invalid"},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.err.Error(); got != c.expect {
+				t.Fatalf("expect %q but got %q", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestValidateIfRequested(t *testing.T) {
+	paths := writeConfigs(t, `{
+		"secure serving": {"secure-port": 8443, "tls-sni-cert-key": ["foo.crt"]},
+		"generic": {"debug": "yes"}
+	}`, `{
+		"profiles": {"debug": {"generic": {"unknown": 1}}}
+	}`)
+
+	o := NewOptions()
+	var f layeredFlags
+	nfs := newLayeredFlagSets(&f)
+	if validated, err := o.ValidateIfRequested(nfs); validated || err != nil {
+		t.Fatalf("expect no validation without --%s, got %v, %v", ValidateFlagName, validated, err)
+	}
+
+	o.ValidateConfig = true
+	o.Paths = paths
+	validated, err := o.ValidateIfRequested(nfs)
+	if !validated {
+		t.Fatal("expect the config files to be validated")
+	}
+	// the violations of all files and profiles are reported, the values are not set
+	var violations []*ValidationError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		for _, v := range e.(interface{ Unwrap() []error }).Unwrap() {
+			var violation *ValidationError
+			if !errors.As(v, &violation) {
+				t.Fatalf("expect a ValidationError but got %v", v)
+			}
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) != 2 || violations[0].Pointer != "/generic/debug" || violations[0].Line != 3 ||
+		violations[1].Pointer != "/profiles/debug/generic/unknown" || violations[1].Line != 2 {
+		t.Fatalf("unexpected violations %v", err)
+	}
+	if f.port != 443 {
+		t.Errorf("expect no value to be set, got port %d", f.port)
+	}
+
+	// the values set on the command line are checked as well
+	paths = writeConfigs(t, `{"secure serving": {"secure-port": 8443, "tls-sni-cert-key": ["foo.crt"]}}`)
+	o.Paths = paths
+	if err = nfs.FlagSets["secure serving"].Parse([]string{"--tls-sni-cert-key=a.crt,a.key"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = o.ValidateIfRequested(nfs)
+	if err == nil || !strings.Contains(err.Error(),
+		`a.json:1:42: /secure serving/tls-sni-cert-key: invalid value for flag "tls-sni-cert-key"`) {
+		t.Fatalf("expect an invalid value error, got %v", err)
+	}
+
+	o.Paths = nil
+	if errs := o.Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "--validate-config requires --config") {
+		t.Fatalf("expect an error for --validate-config without --config, got %v", errs)
+	}
+}
@@ -0,0 +1,370 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/json"
+	"github.com/shipengqi/component-base/util/sets"
+)
+
+// DefaultReloadInterval is the default interval of checking the config files for changes.
+const DefaultReloadInterval = 30 * time.Second
+
+// OptionsFunc creates new options and the NamedFlagSets bound to them, the same way as
+// the component does at startup, including NamedFlagSets.EnvPrefix.
+type OptionsFunc[T any] func() (T, *cliflag.NamedFlagSets)
+
+// ValidateFunc checks validation of the options.
+type ValidateFunc[T any] func(options T) []error
+
+// ChangeEvent describes a change of the options loaded from the config files.
+type ChangeEvent[T any] struct {
+	// Old are the options before the change.
+	Old T
+	// New are the options after the change, the flags in RestartRequired keep their old values,
+	// since they are not applied until the restart.
+	New T
+	// Changed are the names of the changed flags which may change without a restart.
+	Changed []string
+	// RestartRequired are the names of the changed flags which need a restart to be applied.
+	RestartRequired []string
+}
+
+// ChangeListener is called after each change of the options.
+type ChangeListener[T any] func(event ChangeEvent[T])
+
+// Watcher reloads the options from the config files when they change or the process
+// receives SIGHUP. The command line and the environment variables are applied again,
+// so they still take precedence over the config files. The new options are validated
+// and only published to the listeners if they are valid, otherwise the old options
+// are kept.
+type Watcher[T any] struct {
+	options    *Options
+	args       []string
+	newOptions OptionsFunc[T]
+	validate   ValidateFunc[T]
+
+	dynamicFlags  sets.String
+	warningOutput io.Writer
+
+	// reloadLock serializes the reloads.
+	reloadLock sync.Mutex
+	// hash is the sha256 sum of the config file contents of the last reload.
+	hash []byte
+
+	lock      sync.Mutex
+	current   T
+	flags     *cliflag.NamedFlagSets
+	listeners []ChangeListener[T]
+}
+
+// NewWatcher creates a Watcher of the config files of o. args are the command line
+// arguments without the program name, they are parsed into the NamedFlagSets created
+// by newOptions on each reload. validate may be nil if the options need no validation.
+func NewWatcher[T any](o *Options, args []string, newOptions OptionsFunc[T], validate ValidateFunc[T]) *Watcher[T] {
+	return &Watcher[T]{
+		options:       o,
+		args:          args,
+		newOptions:    newOptions,
+		validate:      validate,
+		dynamicFlags:  sets.NewString(),
+		warningOutput: os.Stderr,
+	}
+}
+
+// SetDynamicFlags sets the names of the flags which may change without a restart,
+// the changes of the other flags produce "restart required" warnings.
+func (w *Watcher[T]) SetDynamicFlags(names ...string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.dynamicFlags = sets.NewString(names...)
+}
+
+// SetWarningOutput sets the output of the reload errors and the "restart required" warnings,
+// os.Stderr is used by default.
+func (w *Watcher[T]) SetWarningOutput(output io.Writer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.warningOutput = output
+}
+
+// AddListener adds a listener that is called after each change of the options.
+func (w *Watcher[T]) AddListener(listener ChangeListener[T]) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.listeners = append(w.listeners, listener)
+}
+
+// Load loads the options for the first time and returns them, it must be called before Run.
+func (w *Watcher[T]) Load() (T, error) {
+	w.reloadLock.Lock()
+	defer w.reloadLock.Unlock()
+
+	hash, err := w.configHash()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	options, flags, err := w.load(nil, nil)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	w.hash = hash
+	w.lock.Lock()
+	w.current, w.flags = options, flags
+	w.lock.Unlock()
+	return options, nil
+}
+
+// Current returns the current options.
+func (w *Watcher[T]) Current() T {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.current
+}
+
+// Run polls the config files with the given interval and reloads them on SIGHUP, until the context is done.
+// If interval is zero, DefaultReloadInterval is used. The reload errors are written to the warning output.
+func (w *Watcher[T]) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err = w.CheckNow()
+		case <-hangup:
+			err = w.Reload()
+		}
+		if err != nil {
+			w.warn("Warning: unable to reload config files, keeping the current config: %v\n", err)
+		}
+	}
+}
+
+// CheckNow reloads the options if the contents of the config files have changed since the last reload.
+func (w *Watcher[T]) CheckNow() error {
+	return w.reload(false)
+}
+
+// Reload reloads the options even if the config files have not changed.
+func (w *Watcher[T]) Reload() error {
+	return w.reload(true)
+}
+
+func (w *Watcher[T]) reload(force bool) error {
+	w.reloadLock.Lock()
+	defer w.reloadLock.Unlock()
+
+	hash, err := w.configHash()
+	if err != nil {
+		return err
+	}
+	if !force && bytes.Equal(hash, w.hash) {
+		return nil
+	}
+	// remember the hash even if the reload fails, so the same invalid contents are reported only once
+	w.hash = hash
+
+	options, flags, err := w.load(nil, nil)
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	event := ChangeEvent[T]{Old: w.current, New: options}
+	oldFlags, dynamicFlags := w.flags, w.dynamicFlags
+	w.lock.Unlock()
+	for _, name := range changedFlags(oldFlags, flags) {
+		if dynamicFlags.Has(name) {
+			event.Changed = append(event.Changed, name)
+		} else {
+			event.RestartRequired = append(event.RestartRequired, name)
+		}
+	}
+	if len(event.Changed) == 0 && len(event.RestartRequired) == 0 {
+		return nil
+	}
+	if len(event.RestartRequired) > 0 {
+		// load the options again, with the old values of the flags which require a restart
+		options, flags, err = w.load(oldFlags, sets.NewString(event.RestartRequired...))
+		if err != nil {
+			return err
+		}
+		event.New = options
+	}
+
+	w.lock.Lock()
+	w.current, w.flags = options, flags
+	listeners := make([]ChangeListener[T], len(w.listeners))
+	copy(listeners, w.listeners)
+	w.lock.Unlock()
+
+	for _, name := range event.RestartRequired {
+		w.warn("Warning: flag --%s is changed in the config files, a restart is required to apply it\n", name)
+	}
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return nil
+}
+
+// load creates new options, applies the command line, the environment variables and
+// the config files, and validates the result. The flags whose names are in kept keep
+// their values in keptNFS instead.
+func (w *Watcher[T]) load(keptNFS *cliflag.NamedFlagSets, kept sets.String) (T, *cliflag.NamedFlagSets, error) {
+	var zero T
+	options, nfs := w.newOptions()
+	keptFlags := keptFlags(keptNFS, nfs, kept)
+	for flag := range keptFlags {
+		// the changed flags are skipped by the environment variables and the config files
+		flag.Changed = true
+	}
+
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	fs.SetOutput(io.Discard)
+	for _, name := range nfs.Order {
+		fs.AddFlagSet(nfs.FlagSets[name])
+	}
+	if err := fs.Parse(w.args); err != nil {
+		return zero, nil, err
+	}
+	if err := nfs.SetFromEnv(); err != nil {
+		return zero, nil, err
+	}
+	if err := LoadFilesWithScheme(nfs, w.options.Scheme, w.options.Paths, w.options.Profile); err != nil {
+		return zero, nil, err
+	}
+	for flag, src := range keptFlags {
+		if err := copyFlag(flag, src); err != nil {
+			return zero, nil, fmt.Errorf("unable to keep the value of flag %q: %v", flag.Name, err)
+		}
+	}
+	if w.validate != nil {
+		if errs := w.validate(options); len(errs) > 0 {
+			return zero, nil, errors.Join(errs...)
+		}
+	}
+	return options, nfs, nil
+}
+
+// configHash returns the sha256 sum of the config file contents.
+func (w *Watcher[T]) configHash() ([]byte, error) {
+	h := sha256.New()
+	for _, path := range w.options.Paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read config file: %v", err)
+		}
+		_, _ = h.Write(data)
+		// separate the files, so moving content from a file to the next is a change
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum(nil), nil
+}
+
+func (w *Watcher[T]) warn(format string, args ...interface{}) {
+	w.lock.Lock()
+	output := w.warningOutput
+	w.lock.Unlock()
+
+	_, _ = fmt.Fprintf(output, format, args...)
+}
+
+// keptFlags returns the flags of newNFS whose names are in names, mapped to the flags of
+// the same names in oldNFS.
+func keptFlags(oldNFS, newNFS *cliflag.NamedFlagSets, names sets.String) map[*pflag.Flag]*pflag.Flag {
+	kept := map[*pflag.Flag]*pflag.Flag{}
+	if oldNFS == nil {
+		return kept
+	}
+	for _, name := range newNFS.Order {
+		oldFS := oldNFS.FlagSets[name]
+		if oldFS == nil {
+			continue
+		}
+		newNFS.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if oldFlag := oldFS.Lookup(flag.Name); oldFlag != nil && names.Has(flag.Name) {
+				kept[flag] = oldFlag
+			}
+		})
+	}
+	return kept
+}
+
+// copyFlag sets the flag, which has not been set yet, to the value of src through the JSON
+// encoding of the config files, so the map and slice values are replaced instead of merged.
+// The Changed state and the annotations of src are copied as well.
+func copyFlag(flag, src *pflag.Flag) error {
+	if flag.Value.String() != src.Value.String() {
+		data, err := flagValue(src)
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+		// null is the default value
+		if value != nil {
+			if err = setFlag(flag, value); err != nil {
+				return err
+			}
+		}
+	}
+	flag.Changed = src.Changed
+	flag.Annotations = make(map[string][]string, len(src.Annotations))
+	for k, v := range src.Annotations {
+		flag.Annotations[k] = v
+	}
+	return nil
+}
+
+// changedFlags returns the names of the flags whose values differ between the old and the new NamedFlagSets.
+func changedFlags(oldNFS, newNFS *cliflag.NamedFlagSets) []string {
+	var changed []string
+	for _, name := range newNFS.Order {
+		oldFS := oldNFS.FlagSets[name]
+		newNFS.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if oldFS == nil {
+				changed = append(changed, flag.Name)
+				return
+			}
+			if oldFlag := oldFS.Lookup(flag.Name); oldFlag == nil || oldFlag.Value.String() != flag.Value.String() {
+				changed = append(changed, flag.Name)
+			}
+		})
+	}
+	return changed
+}
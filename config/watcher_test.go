@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+type watchedOptions struct {
+	Port     int
+	Address  string
+	LogLevel string
+}
+
+func newWatchedOptions() (*watchedOptions, *cliflag.NamedFlagSets) {
+	o := &watchedOptions{Port: 443, Address: "0.0.0.0", LogLevel: "info"}
+	nfs := &cliflag.NamedFlagSets{}
+	fs := nfs.FlagSet("secure serving")
+	fs.IntVar(&o.Port, "secure-port", o.Port, "")
+	fs.StringVar(&o.Address, "bind-address", o.Address, "")
+	nfs.FlagSet("logs").StringVar(&o.LogLevel, "log-level", o.LogLevel, "")
+	NewOptions().AddFlags(nfs.FlagSet("global"))
+	return o, nfs
+}
+
+func validateWatchedOptions(o *watchedOptions) []error {
+	if o.Port <= 0 {
+		return []error{errors.New("--secure-port must be positive")}
+	}
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	path := writeConfig(t, `{"secure serving": {"secure-port": 8443, "bind-address": "10.0.0.1"}, "logs": {"log-level": "info"}}`)
+	args := []string{"--config", path, "--bind-address=127.0.0.1", "subcommand-arg"}
+
+	w := NewWatcher[*watchedOptions](&Options{Paths: []string{path}}, args, newWatchedOptions, validateWatchedOptions)
+	w.SetDynamicFlags("log-level")
+	var warnings bytes.Buffer
+	w.SetWarningOutput(&warnings)
+	var events []ChangeEvent[*watchedOptions]
+	w.AddListener(func(event ChangeEvent[*watchedOptions]) {
+		events = append(events, event)
+	})
+
+	initial, err := w.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (&watchedOptions{Port: 8443, Address: "127.0.0.1", LogLevel: "info"}); !reflect.DeepEqual(initial, expect) {
+		t.Fatalf("expect %+v but got %+v", expect, initial)
+	}
+
+	// nothing changed
+	if err = w.CheckNow(); err != nil || len(events) != 0 {
+		t.Fatalf("expect no event, got %v, %v", events, err)
+	}
+	if err = w.Reload(); err != nil || len(events) != 0 {
+		t.Fatalf("expect no event when forced without changes, got %v, %v", events, err)
+	}
+
+	// dynamic change, the command line still takes precedence
+	mustWriteFile(t, path, `{"secure serving": {"secure-port": 8443, "bind-address": "10.0.0.2"}, "logs": {"log-level": "debug"}}`)
+	if err = w.CheckNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expect one event, got %v", events)
+	}
+	if events[0].Old != initial || events[0].New.LogLevel != "debug" || events[0].New.Address != "127.0.0.1" {
+		t.Errorf("unexpected event %+v", events[0])
+	}
+	if !reflect.DeepEqual(events[0].Changed, []string{"log-level"}) || len(events[0].RestartRequired) > 0 {
+		t.Errorf("unexpected changed flags %v and %v", events[0].Changed, events[0].RestartRequired)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("unexpected warnings %q", warnings.String())
+	}
+
+	// change which requires a restart
+	mustWriteFile(t, path, `{"secure serving": {"secure-port": 9443}, "logs": {"log-level": "debug"}}`)
+	if err = w.CheckNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !reflect.DeepEqual(events[1].RestartRequired, []string{"secure-port"}) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if !strings.Contains(warnings.String(), "flag --secure-port is changed in the config files, a restart is required") {
+		t.Errorf("expect a restart required warning, got %q", warnings.String())
+	}
+
+	// invalid config is not published and reported only once
+	current := w.Current()
+	mustWriteFile(t, path, `{"secure serving": {"secure-port": -1}, "logs": {"log-level": "warn"}}`)
+	if err = w.CheckNow(); err == nil || !strings.Contains(err.Error(), "--secure-port must be positive") {
+		t.Fatalf("expect a validation error, got %v", err)
+	}
+	if err = w.CheckNow(); err != nil {
+		t.Fatalf("expect the same invalid contents not to be reported again, got %v", err)
+	}
+	if len(events) != 2 || w.Current() != current {
+		t.Fatalf("expect the old config to be kept, got %+v", w.Current())
+	}
+
+	// unknown flags are errors as well
+	mustWriteFile(t, path, `{"logs": {"unknown": "warn"}}`)
+	if err = w.CheckNow(); err == nil || !strings.Contains(err.Error(), `unknown flag "unknown"`) {
+		t.Fatalf("expect an unknown flag error, got %v", err)
+	}
+}
+
+func mustWriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherRestartRequired(t *testing.T) {
+	path := writeConfig(t, `{"secure serving": {"secure-port": 8443, "labels": {"a": "1"}}, "logs": {"log-level": "info"}}`)
+	type sniOptions struct {
+		*watchedOptions
+		Names  []string
+		Labels map[string]string
+		Env    map[string]string
+	}
+	newOptions := func() (*sniOptions, *cliflag.NamedFlagSets) {
+		o, nfs := newWatchedOptions()
+		so := &sniOptions{watchedOptions: o}
+		nfs.FlagSet("secure serving").StringSliceVar(&so.Names, "sni-names", []string{"a"}, "")
+		nfs.FlagSet("secure serving").Var(cliflag.NewMapStringString(&so.Labels), "labels", "")
+		nfs.FlagSet("secure serving").StringToStringVar(&so.Env, "env", nil, "")
+		return so, nfs
+	}
+	w := NewWatcher[*sniOptions](&Options{Paths: []string{path}}, []string{"--config", path}, newOptions, nil)
+	w.SetDynamicFlags("log-level")
+	var warnings bytes.Buffer
+	w.SetWarningOutput(&warnings)
+	var events []ChangeEvent[*sniOptions]
+	w.AddListener(func(event ChangeEvent[*sniOptions]) {
+		events = append(events, event)
+	})
+	if _, err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the restart required changes are not applied to the current options
+	restartRequired := []string{"env", "labels", "secure-port", "sni-names"}
+	mustWriteFile(t, path, `{"secure serving": {"secure-port": 9443, "sni-names": ["b", "c"], "labels": {"b": "2"}, `+
+		`"env": {"x": "y"}}, "logs": {"log-level": "info"}}`)
+	if err := w.CheckNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !reflect.DeepEqual(events[0].RestartRequired, restartRequired) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	current := w.Current()
+	if events[0].New != current || current.Port != 8443 || !reflect.DeepEqual(current.Names, []string{"a"}) {
+		t.Errorf("expect the old values to be kept, got %+v and %v", current.watchedOptions, current.Names)
+	}
+	if !reflect.DeepEqual(current.Labels, map[string]string{"a": "1"}) || len(current.Env) > 0 {
+		t.Errorf("expect the old maps to be kept, got %v and %v", current.Labels, current.Env)
+	}
+
+	// a later dynamic change doesn't apply them either, they still require a restart
+	mustWriteFile(t, path, `{"secure serving": {"secure-port": 9443, "sni-names": ["b", "c"], "labels": {"b": "2"}, `+
+		`"env": {"x": "y"}}, "logs": {"log-level": "debug"}}`)
+	if err := w.CheckNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !reflect.DeepEqual(events[1].Changed, []string{"log-level"}) ||
+		!reflect.DeepEqual(events[1].RestartRequired, restartRequired) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	current = w.Current()
+	if current.Port != 8443 || !reflect.DeepEqual(current.Names, []string{"a"}) || current.LogLevel != "debug" ||
+		!reflect.DeepEqual(current.Labels, map[string]string{"a": "1"}) || len(current.Env) > 0 {
+		t.Errorf("expect only the dynamic flag to change, got %+v, %v, %v and %v", current.watchedOptions,
+			current.Names, current.Labels, current.Env)
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+	"github.com/shipengqi/component-base/json"
+)
+
+// WriteFlagName is the name of the flag which specifies the path to write the config file to.
+const WriteFlagName = "write-config-to"
+
+// Write writes the current values of the flags of nfs to w as a config document which
+// can be read by LoadFile. The sections are written in the order of nfs.Order, the flags in
+// the order in which their flag set visits them, and the usage of each flag is written as a
+// comment above it.
+// The flags implementing cliflag.OmitEmpty are omitted if they and their defaults are empty.
+// The flags of Options, e.g. --config, are never written.
+func Write(w io.Writer, nfs *cliflag.NamedFlagSets) error {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+
+	firstSection := true
+	for _, name := range nfs.Order {
+		var flags []*pflag.Flag
+		nfs.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			if writable(flag) {
+				flags = append(flags, flag)
+			}
+		})
+		if len(flags) == 0 {
+			continue
+		}
+
+		if !firstSection {
+			buf.WriteString(",")
+		}
+		firstSection = false
+		sectionName, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(&buf, "\n  %s: {", sectionName)
+
+		for i, flag := range flags {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if usage := strings.TrimSpace(flag.Usage); len(usage) > 0 {
+				for _, line := range strings.Split(usage, "\n") {
+					_, _ = fmt.Fprintf(&buf, "\n    // %s", strings.TrimSpace(line))
+				}
+			}
+			flagName, err := json.Marshal(flag.Name)
+			if err != nil {
+				return err
+			}
+			value, err := flagValue(flag)
+			if err != nil {
+				return fmt.Errorf("unable to encode the value of flag %q: %v", flag.Name, err)
+			}
+			_, _ = fmt.Fprintf(&buf, "\n    %s: %s", flagName, value)
+		}
+		buf.WriteString("\n  }")
+	}
+	buf.WriteString("\n}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFile writes the current values of the flags of nfs to the config file at path, see Write.
+func WriteFile(nfs *cliflag.NamedFlagSets, path string) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, nfs); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("unable to write config file: %v", err)
+	}
+	return nil
+}
+
+// optionsFlag returns true if the flag is added by Options.AddFlags, or is the flag of
+// cliflag.AddPrintEffectiveConfigFlag, which can't be set in config files.
+func optionsFlag(name string) bool {
+	return name == FlagName || name == ProfileFlagName || name == WriteFlagName || name == ValidateFlagName ||
+		name == cliflag.PrintEffectiveConfigFlagName
+}
+
+// writable returns true if the flag should be written into the config file.
+func writable(flag *pflag.Flag) bool {
+	if optionsFlag(flag.Name) {
+		return false
+	}
+	if v, ok := flag.Value.(cliflag.OmitEmpty); ok && v.Empty() && len(flag.DefValue) == 0 {
+		return false
+	}
+	return true
+}
+
+// flagValue returns the JSON encoding of the flag value, which gives the same value when it is set by LoadFile.
+// The values have the JSON types of the schema of the flag, see flagSchema.
+func flagValue(flag *pflag.Flag) ([]byte, error) {
+	typ := flag.Value.Type()
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		jsonType := elemType(typ)
+		elems := make([]interface{}, 0, len(slice.GetSlice()))
+		for _, elem := range slice.GetSlice() {
+			elems = append(elems, writtenValue(elem, jsonType))
+		}
+		return json.Marshal(elems)
+	}
+	if _, ok := mapTypes[typ]; ok {
+		if m := mapDefault(flag.Value); m != nil {
+			// the map flags of pflag can't be set to an empty map, they keep their defaults
+			if strings.HasPrefix(typ, "stringTo") && reflect.ValueOf(m).Len() == 0 {
+				return []byte("null"), nil
+			}
+			return json.Marshal(m)
+		}
+	}
+
+	jsonType, ok := scalarTypes[typ]
+	if !ok {
+		jsonType = "string"
+	}
+	return json.Marshal(writtenValue(flag.Value.String(), jsonType))
+}
+
+// writtenValue returns the value of the string of the JSON type, or the string if it can't be parsed.
+// Unlike typedValue, the integers keep all their digits.
+func writtenValue(s string, jsonType string) interface{} {
+	if jsonType == "integer" {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(s)
+		}
+		if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return json.Number(s)
+		}
+	}
+	return typedValue(s, jsonType)
+}
+
+// stripComments replaces the line comments starting with "//" outside JSON strings with spaces,
+// so the offsets of the remaining content are unchanged.
+func stripComments(data []byte) []byte {
+	stripped := make([]byte, len(data))
+	copy(stripped, data)
+
+	inString, escaped, inComment := false, false, false
+	for i := 0; i < len(stripped); i++ {
+		c := stripped[i]
+		switch {
+		case inComment:
+			if c == '\n' {
+				inComment = false
+				continue
+			}
+			stripped[i] = ' '
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(stripped) && stripped[i+1] == '/':
+			inComment = true
+			stripped[i] = ' '
+		}
+	}
+	return stripped
+}
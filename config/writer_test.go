@@ -0,0 +1,203 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+type roundTripFlags struct {
+	testFlags
+	ratio     float64
+	timeout   time.Duration
+	cmds      []string
+	multimap  map[string][]string
+	gates     map[string]bool
+	ciphers   []string
+	emptyMap  map[string]string
+	addresses []string
+	tri       cliflag.Tristate
+	ints      []int
+	env       map[string]string
+	limits    map[string]int64
+}
+
+func newRoundTripFlagSets(f *roundTripFlags) *cliflag.NamedFlagSets {
+	nfs := newTestFlagSets(&f.testFlags)
+	misc := nfs.FlagSet("misc")
+	misc.Float64Var(&f.ratio, "ratio", 0.5, "The ratio.")
+	misc.DurationVar(&f.timeout, "timeout", time.Second, "The timeout.\nIt is a multi-line usage.")
+	misc.Var(cliflag.NewStringSlice(&f.cmds), "cmd", "The commands.")
+	misc.Var(cliflag.NewColonSeparatedMultimapStringString(&f.multimap), "multimap", "The multimap.")
+	misc.Var(cliflag.NewMapStringBool(&f.gates), "gates", "The gates.")
+	misc.Var(cliflag.NewTLSCipherSuitesValue(&f.ciphers), "ciphers", "The ciphers.")
+	misc.Var(cliflag.NewMapStringString(&f.emptyMap), "empty-map", "The empty map.")
+	misc.StringArrayVar(&f.addresses, "address", nil, "The addresses, \"quoted\" // not a comment.")
+	misc.Var(&f.tri, "tri", "The tristate.")
+	misc.IntSliceVar(&f.ints, "ints", []int{1}, "The integers.")
+	misc.StringToStringVar(&f.env, "env", map[string]string{"default": "true"}, "The environment.")
+	misc.StringToInt64Var(&f.limits, "limits", nil, "The limits.")
+	NewOptions().AddFlags(nfs.FlagSet("global"))
+	return nfs
+}
+
+func flagValues(nfs *cliflag.NamedFlagSets) map[string]string {
+	values := map[string]string{}
+	for _, name := range nfs.Order {
+		nfs.FlagSets[name].VisitAll(func(flag *pflag.Flag) {
+			values[flag.Name] = flag.Value.String()
+		})
+	}
+	return values
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	var f roundTripFlags
+	nfs := newRoundTripFlagSets(&f)
+	args := map[string][]string{
+		"secure serving": {"--secure-port=8443", "--tls-sni-cert-key=foo.crt,foo.key:foo.com,www.foo.com",
+			"--tls-sni-cert-key=bar.crt,bar.key"},
+		"generic": {"--debug", "--names=a,b", "--labels=x=y,env=prod"},
+		"misc": {"--ratio=1.25", "--timeout=1m30s", "--cmd=a b", "--cmd=c", "--multimap=k:v1,k:v2,l:v3",
+			"--gates=A=true,B=false", "--ciphers=TLS_RSA_WITH_AES_128_CBC_SHA", `--address=a "quoted" // value`,
+			"--tri=false", "--ints=1,-2", "--env=a=1,b=x=y", "--limits=max=9007199254740993"},
+	}
+	for name, sectionArgs := range args {
+		if err := nfs.FlagSets[name].Parse(sectionArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := WriteFile(nfs, path); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded roundTripFlags
+	loadedNFS := newRoundTripFlagSets(&loaded)
+	if err := LoadFile(loadedNFS, path); err != nil {
+		t.Fatalf("unable to load the written config file: %v\n%s", err, mustReadFile(t, path))
+	}
+	if expect, got := flagValues(nfs), flagValues(loadedNFS); !reflect.DeepEqual(expect, got) {
+		t.Errorf("expect %v but got %v\n%s", expect, got, mustReadFile(t, path))
+	}
+	if !reflect.DeepEqual(f.certKeys, loaded.certKeys) {
+		t.Errorf("expect %v but got %v", f.certKeys, loaded.certKeys)
+	}
+	if !reflect.DeepEqual(f.cmds, loaded.cmds) {
+		t.Errorf("expect %v but got %v", f.cmds, loaded.cmds)
+	}
+	if f.tri != loaded.tri || !reflect.DeepEqual(f.ints, loaded.ints) || !reflect.DeepEqual(f.env, loaded.env) ||
+		!reflect.DeepEqual(f.limits, loaded.limits) {
+		t.Errorf("expect %v, %v, %v and %v but got %v, %v, %v and %v", f.tri, f.ints, f.env, f.limits,
+			loaded.tri, loaded.ints, loaded.env, loaded.limits)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var f roundTripFlags
+	nfs := newRoundTripFlagSets(&f)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, nfs); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, expect := range []string{
+		"    // The timeout.\n    // It is a multi-line usage.\n    \"timeout\": \"1s\"",
+		"    \"ratio\": 0.5,",
+		"    \"debug\": false,",
+		"    \"names\": [\"default\"]\n  }",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("expect %q in\n%s", expect, out)
+		}
+	}
+	for _, unexpected := range []string{`"empty-map"`, `"labels"`, `"global"`, `"config"`, `"write-config-to"`} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("expect no %s in\n%s", unexpected, out)
+		}
+	}
+	if first, second := strings.Index(out, `"secure serving"`), strings.Index(out, `"generic"`); first > second {
+		t.Errorf("expect the sections in order in\n%s", out)
+	}
+}
+
+func TestWriteIfRequested(t *testing.T) {
+	var f roundTripFlags
+	nfs := newRoundTripFlagSets(&f)
+	o := NewOptions()
+
+	written, err := o.WriteIfRequested(nfs)
+	if written || err != nil {
+		t.Fatalf("expect nothing written, got %t, %v", written, err)
+	}
+
+	o.WriteConfigTo = filepath.Join(t.TempDir(), "config.json")
+	written, err = o.WriteIfRequested(nfs)
+	if !written || err != nil {
+		t.Fatalf("expect the config file written, got %t, %v", written, err)
+	}
+	if content := mustReadFile(t, o.WriteConfigTo); !strings.Contains(content, `"secure-port": 443`) {
+		t.Errorf("unexpected config file\n%s", content)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	cases := []struct {
+		desc   string
+		in     string
+		expect string
+	}{
+		{"no comments", `{"a": "b"}`, `{"a": "b"}`},
+		{"line comment", "// c\n{\"a\": 1} // d", "    \n{\"a\": 1}     "},
+		{"slashes in string", `{"a": "http://b"}`, `{"a": "http://b"}`},
+		{"escaped quote", `{"a": "\"//"} // c`, `{"a": "\"//"}     `},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := string(stripComments([]byte(c.in))); got != c.expect {
+				t.Fatalf("expect %q but got %q", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestWriteRoundTripPrintEffectiveConfig(t *testing.T) {
+	newFlagSets := func(f *roundTripFlags, format *string) *cliflag.NamedFlagSets {
+		nfs := newRoundTripFlagSets(f)
+		cliflag.AddPrintEffectiveConfigFlag(nfs.FlagSet("global"), format)
+		return nfs
+	}
+	var (
+		f      roundTripFlags
+		format string
+	)
+	nfs := newFlagSets(&f, &format)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := WriteFile(nfs, path); err != nil {
+		t.Fatal(err)
+	}
+	if content := mustReadFile(t, path); strings.Contains(content, cliflag.PrintEffectiveConfigFlagName) {
+		t.Fatalf("expect no %s in\n%s", cliflag.PrintEffectiveConfigFlagName, content)
+	}
+
+	var (
+		loaded       roundTripFlags
+		loadedFormat string
+	)
+	if err := LoadFile(newFlagSets(&loaded, &loadedFormat), path); err != nil {
+		t.Fatalf("unable to load the written config file: %v\n%s", err, mustReadFile(t, path))
+	}
+	if _, ok := schemaOf(nfs).Defs["global"]; ok {
+		t.Errorf("expect no global section in the schema")
+	}
+}
@@ -0,0 +1,11 @@
+package featuregate
+
+var (
+	// DefaultMutableFeatureGate is a mutable version of DefaultFeatureGate.
+	// Only top-level commands/options setup should make use of this.
+	DefaultMutableFeatureGate = NewFeatureGate()
+
+	// DefaultFeatureGate is a shared global FeatureGate.
+	// Components should use DefaultFeatureGate.Enabled() to check feature gates.
+	DefaultFeatureGate FeatureGate = DefaultMutableFeatureGate
+)
@@ -0,0 +1,3 @@
+// Package featuregate keeps a registry of named features with their default
+// values and maturity stages, and exposes them through a "--feature-gates" flag.
+package featuregate
@@ -0,0 +1,313 @@
+package featuregate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+type prerelease string
+
+const (
+	// Alpha features are disabled by default and may change or be removed without notice.
+	Alpha = prerelease("ALPHA")
+	// Beta features are well tested and usually enabled by default.
+	Beta = prerelease("BETA")
+	// GA features are always enabled, the gate is kept only for compatibility.
+	GA = prerelease("")
+	// Deprecated features will be removed in a future release.
+	Deprecated = prerelease("DEPRECATED")
+
+	flagName = "feature-gates"
+)
+
+// FeatureSpec describes the default value, the maturity stage and
+// whether the value of a feature gate may be changed.
+type FeatureSpec struct {
+	// Default is the default enablement state for the feature.
+	Default bool
+	// LockToDefault indicates that the feature is locked to its default
+	// and cannot be changed.
+	LockToDefault bool
+	// PreRelease indicates the maturity level of the feature.
+	PreRelease prerelease
+}
+
+// FeatureGate indicates whether a given feature is enabled or not.
+type FeatureGate interface {
+	// Enabled returns true if the key is enabled.
+	Enabled(key Feature) bool
+	// KnownFeatures returns a slice of strings describing the FeatureGate's known features.
+	KnownFeatures() []string
+	// DeepCopy returns a deep copy of the FeatureGate object, such that gates can be
+	// set on the copy without mutating the original.
+	DeepCopy() MutableFeatureGate
+}
+
+// MutableFeatureGate parses and stores flag gates for known features from
+// a string like feature1=true,feature2=false,...
+type MutableFeatureGate interface {
+	FeatureGate
+
+	// AddFlag adds a flag for setting global feature gates to the specified FlagSet.
+	AddFlag(fs *pflag.FlagSet)
+	// Set parses and stores flag gates for known features
+	// from a string like feature1=true,feature2=false,...
+	Set(value string) error
+	// SetFromMap stores flag gates for known features from a map[string]bool or returns an error
+	SetFromMap(m map[string]bool) error
+	// Add adds features to the featureGate.
+	Add(features map[Feature]FeatureSpec) error
+	// GetAll returns a copy of the map of known feature names to feature specs.
+	GetAll() map[Feature]FeatureSpec
+	// SetWarningOutput sets the destination for warnings about deprecated or GA
+	// feature gates being set. If w is nil, os.Stderr is used.
+	SetWarningOutput(w io.Writer)
+}
+
+// featureGate implements FeatureGate as well as pflag.Value for flag parsing.
+type featureGate struct {
+	// lock guards writes to known, enabled, and reads/writes of closed
+	lock sync.Mutex
+	// known holds a map[Feature]FeatureSpec
+	known atomic.Value
+	// enabled holds a map[Feature]bool
+	enabled atomic.Value
+	// closed is set to true when AddFlag is called, and prevents subsequent calls to Add
+	closed bool
+	// warnings is the destination of the deprecation warnings
+	warnings io.Writer
+}
+
+var _ pflag.Value = &featureGate{}
+
+// NewFeatureGate creates an empty MutableFeatureGate.
+func NewFeatureGate() MutableFeatureGate {
+	known := map[Feature]FeatureSpec{}
+	enabled := map[Feature]bool{}
+
+	f := &featureGate{}
+	f.known.Store(known)
+	f.enabled.Store(enabled)
+
+	return f
+}
+
+// Set parses a string of the form "key1=value1,key2=value2,..." into a
+// map[string]bool of known keys or returns an error.
+func (f *featureGate) Set(value string) error {
+	m := make(map[string]bool)
+	if err := cliflag.NewMapStringBool(&m).Set(value); err != nil {
+		return err
+	}
+	return f.SetFromMap(m)
+}
+
+// SetFromMap stores flag gates for known features from a map[string]bool or returns an error
+func (f *featureGate) SetFromMap(m map[string]bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Copy existing state
+	known := map[Feature]FeatureSpec{}
+	for k, v := range f.known.Load().(map[Feature]FeatureSpec) {
+		known[k] = v
+	}
+	enabled := map[Feature]bool{}
+	for k, v := range f.enabled.Load().(map[Feature]bool) {
+		enabled[k] = v
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Validate all keys before warning, so that nothing is printed if the map is rejected
+	for _, k := range keys {
+		v := m[k]
+		spec, ok := known[Feature(k)]
+		if !ok {
+			return fmt.Errorf("unrecognized feature gate: %s", k)
+		}
+		if spec.LockToDefault && spec.Default != v {
+			return fmt.Errorf("cannot set feature gate %v to %v, feature is locked to %v", k, v, spec.Default)
+		}
+	}
+
+	for _, k := range keys {
+		v := m[k]
+		spec := known[Feature(k)]
+		enabled[Feature(k)] = v
+
+		if spec.PreRelease == Deprecated {
+			f.warnf("Setting deprecated feature gate %s=%t. It will be removed in a future release.", k, v)
+		} else if spec.PreRelease == GA {
+			f.warnf("Setting GA feature gate %s=%t. It will be removed in a future release.", k, v)
+		}
+	}
+
+	// Persist changes
+	f.known.Store(known)
+	f.enabled.Store(enabled)
+
+	return nil
+}
+
+// String returns a string containing all enabled feature gates, formatted as "key1=value1,key2=value2,...".
+func (f *featureGate) String() string {
+	pairs := []string{}
+	for k, v := range f.enabled.Load().(map[Feature]bool) {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Type implements github.com/spf13/pflag.Value
+func (f *featureGate) Type() string {
+	return "mapStringBool"
+}
+
+// Add adds features to the featureGate.
+func (f *featureGate) Add(features map[Feature]FeatureSpec) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("cannot add a feature gate after adding it to the flag set")
+	}
+
+	// Copy existing state
+	known := map[Feature]FeatureSpec{}
+	for k, v := range f.known.Load().(map[Feature]FeatureSpec) {
+		known[k] = v
+	}
+
+	for name, spec := range features {
+		if existingSpec, found := known[name]; found {
+			if existingSpec == spec {
+				continue
+			}
+			return fmt.Errorf("feature gate %q with different spec already exists: %v", name, existingSpec)
+		}
+
+		known[name] = spec
+	}
+
+	// Persist updated state
+	f.known.Store(known)
+
+	return nil
+}
+
+// GetAll returns a copy of the map of known feature names to feature specs.
+func (f *featureGate) GetAll() map[Feature]FeatureSpec {
+	retval := map[Feature]FeatureSpec{}
+	for k, v := range f.known.Load().(map[Feature]FeatureSpec) {
+		retval[k] = v
+	}
+	return retval
+}
+
+// Enabled returns true if the key is enabled. If the key is not known, this call will panic.
+func (f *featureGate) Enabled(key Feature) bool {
+	if v, ok := f.enabled.Load().(map[Feature]bool)[key]; ok {
+		return v
+	}
+	if v, ok := f.known.Load().(map[Feature]FeatureSpec)[key]; ok {
+		return v.Default
+	}
+
+	panic(fmt.Errorf("feature %q is not registered in FeatureGate", key))
+}
+
+// AddFlag adds a flag for setting global feature gates to the specified FlagSet.
+func (f *featureGate) AddFlag(fs *pflag.FlagSet) {
+	f.lock.Lock()
+	// no more features can be added once the flag is registered,
+	// otherwise the help text would be incomplete.
+	f.closed = true
+	f.lock.Unlock()
+
+	known := f.KnownFeatures()
+	fs.Var(f, flagName, ""+
+		"A set of key=value pairs that describe feature gates for alpha/experimental features. "+
+		"Options are:\n"+strings.Join(known, "\n"))
+}
+
+// KnownFeatures returns a sorted slice of strings describing the FeatureGate's known features.
+func (f *featureGate) KnownFeatures() []string {
+	var known []string
+	for k, v := range f.known.Load().(map[Feature]FeatureSpec) {
+		stage := string(v.PreRelease)
+		if v.PreRelease == GA {
+			stage = "GA"
+		}
+		if v.LockToDefault {
+			stage += ", locked"
+		}
+		known = append(known, fmt.Sprintf("%s=true|false (%s - default=%t)", k, stage, v.Default))
+	}
+	sort.Strings(known)
+	return known
+}
+
+// SetWarningOutput sets the destination for warnings about deprecated or GA
+// feature gates being set. If w is nil, os.Stderr is used.
+func (f *featureGate) SetWarningOutput(w io.Writer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.warnings = w
+}
+
+// DeepCopy returns a deep copy of the FeatureGate object, such that gates can be
+// set on the copy without mutating the original. This is useful for validating
+// config against potential feature gate changes before committing those changes.
+func (f *featureGate) DeepCopy() MutableFeatureGate {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Copy existing state.
+	known := map[Feature]FeatureSpec{}
+	for k, v := range f.known.Load().(map[Feature]FeatureSpec) {
+		known[k] = v
+	}
+	enabled := map[Feature]bool{}
+	for k, v := range f.enabled.Load().(map[Feature]bool) {
+		enabled[k] = v
+	}
+
+	// Construct a new featureGate around the copied state.
+	// We maintain the value of f.closed across the copy.
+	fg := &featureGate{
+		closed:   f.closed,
+		warnings: f.warnings,
+	}
+	fg.known.Store(known)
+	fg.enabled.Store(enabled)
+
+	return fg
+}
+
+// warnf writes a warning, the caller must hold the lock.
+func (f *featureGate) warnf(format string, args ...interface{}) {
+	w := f.warnings
+	if w == nil {
+		w = os.Stderr
+	}
+	_, _ = fmt.Fprintf(w, "Warning: "+format+"\n", args...)
+}
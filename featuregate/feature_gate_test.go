@@ -0,0 +1,216 @@
+package featuregate
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	testAlphaGate      Feature = "TestAlpha"
+	testBetaGate       Feature = "TestBeta"
+	testGAGate         Feature = "TestGA"
+	testDeprecatedGate Feature = "TestDeprecated"
+	testLockedGate     Feature = "TestLocked"
+)
+
+func newTestFeatureGate(t *testing.T) MutableFeatureGate {
+	f := NewFeatureGate()
+	err := f.Add(map[Feature]FeatureSpec{
+		testAlphaGate:      {Default: false, PreRelease: Alpha},
+		testBetaGate:       {Default: true, PreRelease: Beta},
+		testGAGate:         {Default: true, PreRelease: GA},
+		testDeprecatedGate: {Default: false, PreRelease: Deprecated},
+		testLockedGate:     {Default: true, PreRelease: GA, LockToDefault: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFeatureGateFlag(t *testing.T) {
+	tests := []struct {
+		arg        string
+		expect     map[Feature]bool
+		parseError string
+		warning    string
+	}{
+		{
+			arg: "",
+			expect: map[Feature]bool{
+				testAlphaGate: false,
+				testBetaGate:  true,
+			},
+		},
+		{
+			arg: "TestAlpha=true,TestBeta=false",
+			expect: map[Feature]bool{
+				testAlphaGate: true,
+				testBetaGate:  false,
+			},
+		},
+		{
+			arg:        "fooBarBaz=true",
+			parseError: "unrecognized feature gate: fooBarBaz",
+		},
+		{
+			arg:        "TestAlpha=maybe",
+			parseError: "invalid value of TestAlpha",
+		},
+		{
+			arg:        "TestLocked=false",
+			parseError: "feature is locked to true",
+		},
+		{
+			arg:     "TestLocked=true",
+			expect:  map[Feature]bool{testLockedGate: true},
+			warning: "Setting GA feature gate TestLocked=true",
+		},
+		{
+			arg:     "TestDeprecated=true",
+			expect:  map[Feature]bool{testDeprecatedGate: true},
+			warning: "Setting deprecated feature gate TestDeprecated=true",
+		},
+	}
+	for i, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			fs := pflag.NewFlagSet("testfeaturegateflag", pflag.ContinueOnError)
+			f := newTestFeatureGate(t)
+			var warnings bytes.Buffer
+			f.SetWarningOutput(&warnings)
+			f.AddFlag(fs)
+
+			err := fs.Parse([]string{"--feature-gates=" + test.arg})
+			if test.parseError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.parseError) {
+					t.Errorf("%d: expected error containing %q, got %v", i, test.parseError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("%d: unexpected error: %v", i, err)
+			}
+			for k, v := range test.expect {
+				if actual := f.Enabled(k); actual != v {
+					t.Errorf("%d: expected %s=%v, got %v", i, k, v, actual)
+				}
+			}
+			if !strings.Contains(warnings.String(), test.warning) {
+				t.Errorf("%d: expected warning %q, got %q", i, test.warning, warnings.String())
+			}
+		})
+	}
+}
+
+func TestFeatureGateAdd(t *testing.T) {
+	f := newTestFeatureGate(t)
+	if err := f.Add(map[Feature]FeatureSpec{testAlphaGate: {Default: false, PreRelease: Alpha}}); err != nil {
+		t.Errorf("expected re-adding an identical spec to succeed, got %v", err)
+	}
+	if err := f.Add(map[Feature]FeatureSpec{testAlphaGate: {Default: true, PreRelease: Beta}}); err == nil {
+		t.Errorf("expected re-adding a different spec to fail")
+	}
+	f.AddFlag(pflag.NewFlagSet("test", pflag.ContinueOnError))
+	if err := f.Add(map[Feature]FeatureSpec{"New": {}}); err == nil {
+		t.Errorf("expected adding a feature after AddFlag to fail")
+	}
+}
+
+func TestFeatureGateKnownFeatures(t *testing.T) {
+	f := newTestFeatureGate(t)
+	expected := []string{
+		"TestAlpha=true|false (ALPHA - default=false)",
+		"TestBeta=true|false (BETA - default=true)",
+		"TestDeprecated=true|false (DEPRECATED - default=false)",
+		"TestGA=true|false (GA - default=true)",
+		"TestLocked=true|false (GA, locked - default=true)",
+	}
+	known := f.KnownFeatures()
+	if strings.Join(known, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, known)
+	}
+}
+
+func TestFeatureGateEnabledUnknown(t *testing.T) {
+	f := NewFeatureGate()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected Enabled to panic for an unknown feature")
+		}
+	}()
+	f.Enabled("Unknown")
+}
+
+func TestFeatureGateDeepCopy(t *testing.T) {
+	f := newTestFeatureGate(t)
+	c := f.DeepCopy()
+	if err := c.Set("TestAlpha=true"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Enabled(testAlphaGate) {
+		t.Errorf("expected the original feature gate to be unchanged")
+	}
+	if !c.Enabled(testAlphaGate) {
+		t.Errorf("expected the copied feature gate to be changed")
+	}
+}
+
+func TestFeatureGateConcurrentEnabled(t *testing.T) {
+	f := newTestFeatureGate(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = f.Enabled(testAlphaGate)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = f.SetFromMap(map[string]bool{string(testAlphaGate): true})
+		}()
+	}
+	wg.Wait()
+	if !f.Enabled(testAlphaGate) {
+		t.Errorf("expected %s to be enabled", testAlphaGate)
+	}
+}
+
+func TestFeatureGateConcurrentDeepCopy(t *testing.T) {
+	f := newTestFeatureGate(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_ = f.DeepCopy()
+		}()
+		go func() {
+			defer wg.Done()
+			f.AddFlag(pflag.NewFlagSet("test", pflag.ContinueOnError))
+		}()
+		go func() {
+			defer wg.Done()
+			f.SetWarningOutput(&bytes.Buffer{})
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFeatureGateSetFromMapInvalid(t *testing.T) {
+	f := newTestFeatureGate(t)
+	var warnings bytes.Buffer
+	f.SetWarningOutput(&warnings)
+	if err := f.SetFromMap(map[string]bool{string(testGAGate): false, "Unknown": true}); err == nil {
+		t.Fatal("expect an error of the unknown feature gate")
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("expect no warnings of a rejected map but got %q", warnings.String())
+	}
+	if !f.Enabled(testGAGate) {
+		t.Errorf("expect %s to keep its default", testGAGate)
+	}
+}
@@ -4,6 +4,9 @@ package json
 
 import json "github.com/goccy/go-json"
 
+// Number is exported by component-base/json package.
+type Number = json.Number
+
 var (
 	// Marshal is exported by component-base/json package.
 	Marshal = json.Marshal
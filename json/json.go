@@ -7,6 +7,9 @@ import "encoding/json"
 // RawMessage is exported by component-base/json package.
 type RawMessage = json.RawMessage
 
+// Number is exported by component-base/json package.
+type Number = json.Number
+
 var (
 	// Marshal is exported by component-base/pkg/json package.
 	Marshal = json.Marshal
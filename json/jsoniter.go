@@ -2,11 +2,19 @@
 
 package json
 
-import jsoniter "github.com/json-iterator/go"
+import (
+	stdjson "encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+)
 
 // RawMessage is exported by component-base/json package.
 type RawMessage = jsoniter.RawMessage
 
+// Number is exported by component-base/json package, the decoders with UseNumber
+// decode the numbers into the Number of encoding/json.
+type Number = stdjson.Number
+
 var (
 	json = jsoniter.ConfigCompatibleWithStandardLibrary
 	// Marshal is exported by component-base/json package.
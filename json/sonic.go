@@ -2,7 +2,15 @@
 
 package json
 
-import "github.com/bytedance/sonic"
+import (
+	stdjson "encoding/json"
+
+	"github.com/bytedance/sonic"
+)
+
+// Number is exported by component-base/json package, the decoders with UseNumber
+// decode the numbers into the Number of encoding/json.
+type Number = stdjson.Number
 
 var (
 	json = sonic.ConfigStd
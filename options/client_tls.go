@@ -0,0 +1,244 @@
+package options
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/shipengqi/component-base/cert"
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// DefaultClientFlagPrefix is the default prefix of the client TLS flag names.
+const DefaultClientFlagPrefix = "client-"
+
+// ClientTLSOptions contains the options for connecting to other services with TLS.
+type ClientTLSOptions struct {
+	// FlagPrefix is the prefix of the flag names, it allows registering the options
+	// of several services into one FlagSet.
+	FlagPrefix string
+	// CAFile is a file containing the CA certificates for verifying the server certificates.
+	CAFile string
+	// CADir is a directory containing the CA certificates for verifying the server certificates,
+	// all the "*.crt" and "*.pem" files in it are loaded.
+	CADir string
+	// CertFile is a file containing the client certificate, it is reloaded when it changes.
+	CertFile string
+	// KeyFile is a file containing the private key matching CertFile, it is reloaded when it changes.
+	KeyFile string
+	// ServerName overrides the server name used to verify the server certificate.
+	ServerName string
+	// InsecureSkipVerify disables the verification of the server certificates.
+	InsecureSkipVerify bool
+	// CipherSuites is the list of allowed cipher suites for the client.
+	CipherSuites []string
+	// MinTLSVersion is the minimum TLS version supported.
+	MinTLSVersion string
+}
+
+// NewClientTLSOptions creates a ClientTLSOptions with default parameters.
+func NewClientTLSOptions() *ClientTLSOptions {
+	return &ClientTLSOptions{
+		FlagPrefix: DefaultClientFlagPrefix,
+	}
+}
+
+// AddFlags adds flags related to client TLS to the specified FlagSet,
+// which is usually a section of NamedFlagSets.
+func (o *ClientTLSOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+
+	fs.StringVar(&o.CAFile, o.FlagPrefix+"ca-file", o.CAFile,
+		"File containing the CA certificates for verifying the server certificates. "+
+			"If neither this nor --"+o.FlagPrefix+"ca-dir is set, the system CA certificates are used.")
+
+	fs.StringVar(&o.CADir, o.FlagPrefix+"ca-dir", o.CADir,
+		"Directory containing the CA certificates for verifying the server certificates, "+
+			"all the *.crt and *.pem files in it are loaded.")
+
+	fs.StringVar(&o.CertFile, o.FlagPrefix+"cert-file", o.CertFile,
+		"File containing the x509 client certificate, it is reloaded when it changes.")
+
+	fs.StringVar(&o.KeyFile, o.FlagPrefix+"key-file", o.KeyFile,
+		"File containing the x509 private key matching --"+o.FlagPrefix+"cert-file, it is reloaded when it changes.")
+
+	fs.StringVar(&o.ServerName, o.FlagPrefix+"server-name", o.ServerName,
+		"Server name used to verify the server certificate, instead of the host name of the server address.")
+
+	fs.BoolVar(&o.InsecureSkipVerify, o.FlagPrefix+"insecure-skip-tls-verify", o.InsecureSkipVerify,
+		"If true, the server certificates will not be checked for validity. "+
+			"This makes the connections INSECURE and vulnerable to man-in-the-middle attacks, "+
+			"only use it for testing.")
+
+	fs.Var(cliflag.NewTLSCipherSuitesValue(&o.CipherSuites), o.FlagPrefix+"tls-cipher-suites",
+		"Comma-separated list of cipher suites for the client. "+
+			"If omitted, the default Go cipher suites will be used. "+
+			"Use \"help\" to print the details of all cipher suites and exit. \n"+
+			"Preferred values: "+strings.Join(cliflag.PreferredTLSCipherNames(), ", ")+". \n"+
+			"Insecure values: "+strings.Join(cliflag.InsecureTLSCipherNames(), ", ")+".")
+
+	fs.StringVar(&o.MinTLSVersion, o.FlagPrefix+"tls-min-version", o.MinTLSVersion,
+		"Minimum TLS version supported. "+
+			"Possible values: "+strings.Join(cliflag.TLSPossibleVersions(), ", "))
+}
+
+// Validate checks validation of ClientTLSOptions.
+func (o *ClientTLSOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+
+	var errs []error
+
+	if (len(o.CertFile) == 0) != (len(o.KeyFile) == 0) {
+		errs = append(errs, fmt.Errorf("--%scert-file and --%skey-file must be specified together", o.FlagPrefix, o.FlagPrefix))
+	}
+
+	if o.InsecureSkipVerify && (len(o.CAFile) > 0 || len(o.CADir) > 0) {
+		errs = append(errs, fmt.Errorf("--%sinsecure-skip-tls-verify is not allowed with CA certificates", o.FlagPrefix))
+	}
+
+	if _, err := cliflag.TLSCipherSuites(o.CipherSuites); err != nil {
+		errs = append(errs, fmt.Errorf("--%stls-cipher-suites: %v", o.FlagPrefix, err))
+	}
+
+	if minVersion, err := cliflag.TLSVersion(o.MinTLSVersion); err != nil {
+		errs = append(errs, fmt.Errorf("--%stls-min-version: %v", o.FlagPrefix, err))
+	} else if _, err = fipsMaxVersion(minVersion); err != nil {
+		errs = append(errs, fmt.Errorf("--%stls-min-version: %v", o.FlagPrefix, err))
+	}
+
+	return errs
+}
+
+// Warnings returns the warnings about the options, e.g. the server certificates
+// are not verified.
+func (o *ClientTLSOptions) Warnings() []string {
+	if o == nil {
+		return nil
+	}
+
+	var warnings []string
+	if o.InsecureSkipVerify {
+		warnings = append(warnings, fmt.Sprintf("--%sinsecure-skip-tls-verify is set, the server certificates are NOT verified "+
+			"and the connections are vulnerable to man-in-the-middle attacks, do NOT use it in production", o.FlagPrefix))
+	}
+	if len(o.CipherSuites) > 0 {
+		cipherWarnings, _ := cliflag.ValidateTLSCipherSuites(o.MinTLSVersion, "", o.CipherSuites, false)
+		warnings = append(warnings, cipherWarnings...)
+	}
+	return warnings
+}
+
+// TLSConfig builds a *tls.Config for http.Transport from the options.
+// In FIPS mode, the FIPS 140 approved cipher suites and curves are used unless they are given,
+// and the TLS version is limited by cliflag.TLSFIPSMaxVersion.
+// The client certificate is loaded once, use DynamicTLSConfig to pick up rotated certificates.
+func (o *ClientTLSOptions) TLSConfig() (*tls.Config, error) {
+	config, _, err := o.tlsConfig()
+	return config, err
+}
+
+// DynamicTLSConfig is like TLSConfig, but the client certificate and key files are
+// polled with the given interval and reloaded when they change, until the
+// context is done. Listeners are told about each reload.
+func (o *ClientTLSOptions) DynamicTLSConfig(ctx context.Context, interval time.Duration, listeners ...cert.Listener) (*tls.Config, error) {
+	config, certs, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if certs != nil {
+		for _, listener := range listeners {
+			certs.AddListener(listener)
+		}
+		go certs.Run(ctx, interval)
+	}
+	return config, nil
+}
+
+func (o *ClientTLSOptions) tlsConfig() (*tls.Config, *cert.DynamicServingCertificates, error) {
+	cipherNames, curveNames := fipsDefaults(o.CipherSuites, nil)
+	cipherSuites, err := cliflag.TLSCipherSuites(cipherNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	minVersion, err := cliflag.TLSVersion(o.MinTLSVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxVersion, err := fipsMaxVersion(minVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	curvePreferences, err := cliflag.TLSCurves(curveNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// #nosec G402 -- InsecureSkipVerify must be set explicitly and is warned about
+	config := &tls.Config{
+		MinVersion:         minVersion,
+		MaxVersion:         maxVersion,
+		CipherSuites:       cipherSuites,
+		CurvePreferences:   curvePreferences,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if len(o.CAFile) > 0 || len(o.CADir) > 0 {
+		pool, err := o.certPool()
+		if err != nil {
+			return nil, nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if len(o.CertFile) == 0 || len(o.KeyFile) == 0 {
+		return config, nil, nil
+	}
+	certs, err := cert.NewDynamicServingCertificates(&cliflag.NamedCertKey{CertFile: o.CertFile, KeyFile: o.KeyFile}, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load client certificate: %v", err)
+	}
+	config.GetClientCertificate = certs.GetClientCertificate
+
+	return config, certs, nil
+}
+
+// certPool loads the CA certificates from CAFile and CADir.
+func (o *ClientTLSOptions) certPool() (*x509.CertPool, error) {
+	var files []string
+	if len(o.CAFile) > 0 {
+		files = append(files, o.CAFile)
+	}
+	if len(o.CADir) > 0 {
+		for _, pattern := range []string{"*.crt", "*.pem"} {
+			matches, err := filepath.Glob(filepath.Join(o.CADir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificates: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no CA certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,133 @@
+package options
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+func TestClientTLSOptionsValidate(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errors int
+	}{
+		{
+			args: []string{},
+		},
+		{
+			args:   []string{"--client-cert-file=foo.crt"},
+			errors: 1,
+		},
+		{
+			args:   []string{"--client-insecure-skip-tls-verify", "--client-ca-file=ca.crt"},
+			errors: 1,
+		},
+		{
+			args:   []string{"--client-tls-cipher-suites=foo", "--client-tls-min-version=foo"},
+			errors: 2,
+		},
+	}
+
+	for i, test := range tests {
+		o := NewClientTLSOptions()
+		var fss cliflag.NamedFlagSets
+		o.AddFlags(fss.FlagSet("client tls"))
+		if err := fss.FlagSet("client tls").Parse(test.args); err != nil {
+			t.Fatalf("%d: unexpected parse error: %v", i, err)
+		}
+		if errs := o.Validate(); len(errs) != test.errors {
+			t.Errorf("%d: expected %d errors, got %v", i, test.errors, errs)
+		}
+	}
+}
+
+func TestClientTLSOptionsTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	caDir := filepath.Join(dir, "ca")
+	if err := os.Mkdir(caDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := writeTestCertKey(t, caDir, "ca")
+	clientCert, clientKey := writeTestCertKey(t, dir, "client")
+
+	o := NewClientTLSOptions()
+	o.CADir = caDir
+	o.CertFile, o.KeyFile = clientCert, clientKey
+	o.ServerName = "foo.com"
+	o.MinTLSVersion = "VersionTLS13"
+
+	config, err := o.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 || config.ServerName != "foo.com" || config.InsecureSkipVerify {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	data, err := os.ReadFile(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPool := x509.NewCertPool()
+	expectedPool.AppendCertsFromPEM(data)
+	if config.RootCAs == nil || !config.RootCAs.Equal(expectedPool) {
+		t.Errorf("expected the CA certificates of the CA directory")
+	}
+
+	cert, err := config.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "client" {
+		t.Errorf("expected the client certificate, got %q", leaf.Subject.CommonName)
+	}
+}
+
+func TestClientTLSOptionsWarnings(t *testing.T) {
+	o := NewClientTLSOptions()
+	if warnings := o.Warnings(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	o.InsecureSkipVerify = true
+	warnings := o.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "--client-insecure-skip-tls-verify") {
+		t.Errorf("expected a warning about the insecure flag, got %v", warnings)
+	}
+}
+
+func TestClientTLSOptionsFIPSMode(t *testing.T) {
+	cliflag.SetTLSFIPSMode(true)
+	defer cliflag.SetTLSFIPSMode(false)
+
+	config, err := NewClientTLSOptions().TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCiphers, _ := cliflag.TLSCipherSuites(cliflag.TLSCipherPossibleValues())
+	if !reflect.DeepEqual(config.CipherSuites, expectedCiphers) {
+		t.Errorf("expected the FIPS cipher suites %v, got %v", expectedCiphers, config.CipherSuites)
+	}
+	if expected := []tls.CurveID{tls.CurveP256, tls.CurveP384}; !reflect.DeepEqual(config.CurvePreferences, expected) {
+		t.Errorf("expected the FIPS curves %v, got %v", expected, config.CurvePreferences)
+	}
+
+	o := NewClientTLSOptions()
+	o.CipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+	if config, err = o.TLSConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("expected the given cipher suite, got %v", config.CipherSuites)
+	}
+}
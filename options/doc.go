@@ -0,0 +1,5 @@
+// Package options provides reusable option groups for components,
+// each group registers its own flags and validates its own values.
+// The groups are composed into the Options of a component, which a Runner
+// parses, completes and validates.
+package options
@@ -0,0 +1,242 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// Options is the interface of the options of a component, which are usually composed of
+// option groups such as SecureServingOptions, e.g.
+//
+//	func (o *ServerOptions) Flags() (fss cliflag.NamedFlagSets) {
+//		o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
+//		o.FeatureGate.AddFlag(fss.FlagSet("feature gates"))
+//		return fss
+//	}
+//
+//	func (o *ServerOptions) Complete() error {
+//		return CompleteAll(o.SecureServing, o.Config)
+//	}
+//
+//	func (o *ServerOptions) Validate() []error {
+//		return ValidateAll(o.SecureServing, o.Config)
+//	}
+type Options interface {
+	// Flags returns the flags of the options in sections.
+	Flags() cliflag.NamedFlagSets
+	// Complete sets the default values which depend on other options, after the flags are parsed.
+	Complete() error
+	// Validate checks the options and returns all errors.
+	Validate() []error
+}
+
+// Completer is an option group which has defaults depending on other options.
+type Completer interface {
+	Complete() error
+}
+
+// Validator is an option group which validates its own values.
+type Validator interface {
+	Validate() []error
+}
+
+// Warner is an option group which has warnings about values which are valid, but questionable,
+// e.g. insecure settings. The warnings are printed by Runner and the app package.
+type Warner interface {
+	Warnings() []string
+}
+
+// CompleteAll completes the option groups which implement Completer in order, it returns
+// the first error. The other groups are ignored, so that all groups of the options can be passed.
+func CompleteAll(groups ...interface{}) error {
+	for _, group := range groups {
+		if c, ok := group.(Completer); ok {
+			if err := c.Complete(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAll returns the errors of all option groups which implement Validator.
+// The other groups are ignored, so that all groups of the options can be passed.
+func ValidateAll(groups ...interface{}) []error {
+	var errs []error
+	for _, group := range groups {
+		if v, ok := group.(Validator); ok {
+			errs = append(errs, v.Validate()...)
+		}
+	}
+	return errs
+}
+
+// WarningsAll returns the warnings of all option groups which implement Warner.
+// The other groups are ignored, so that all groups of the options can be passed.
+func WarningsAll(groups ...interface{}) []string {
+	var warnings []string
+	for _, group := range groups {
+		if w, ok := group.(Warner); ok {
+			warnings = append(warnings, w.Warnings()...)
+		}
+	}
+	return warnings
+}
+
+// FlagError is an error of the value of a flag.
+type FlagError struct {
+	// Section is the name of the flag section, it is empty if it is unknown.
+	Section string
+	// Flag is the name of the flag without "--", it is empty if the error is not
+	// about a single flag.
+	Flag string
+	Err  error
+}
+
+// Error returns the section in brackets and the flag, followed by the error, e.g.
+// "[secure serving] --tls-min-version: unknown tls version".
+func (e *FlagError) Error() string {
+	var b strings.Builder
+	if len(e.Section) > 0 {
+		b.WriteString("[" + e.Section + "] ")
+	}
+	if len(e.Flag) > 0 {
+		b.WriteString("--" + e.Flag + ": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *FlagError) Unwrap() error {
+	return e.Err
+}
+
+// CompleteAndValidate completes and validates the options whose flags are fss,
+// the errors are labelled with the sections and the flags, see LabelErrors.
+func CompleteAndValidate(o Options, fss cliflag.NamedFlagSets) error {
+	if err := o.Complete(); err != nil {
+		return LabelErrors(fss, []error{err})
+	}
+	if errs := o.Validate(); len(errs) > 0 {
+		return LabelErrors(fss, errs)
+	}
+	return nil
+}
+
+// LabelErrors returns the errors joined as FlagErrors labelled with the sections of fss.
+// The errors which are not FlagErrors are labelled with the flag at their beginning if it is
+// one of fss, the option groups report the errors in this form, e.g. "--tls-min-version: unknown
+// tls version" is the error "unknown tls version" of the flag tls-min-version. Only the section
+// is labelled for the other forms, e.g. "--tls-cert-file and --tls-private-key-file must be
+// specified together". It returns nil if there is no error.
+func LabelErrors(fss cliflag.NamedFlagSets, errs []error) error {
+	labelled := make([]error, 0, len(errs))
+	for _, err := range errs {
+		flagErr := &FlagError{Err: err}
+		var fe *FlagError
+		if errors.As(err, &fe) {
+			*flagErr = *fe
+		} else if msg := err.Error(); strings.HasPrefix(msg, "--") {
+			name := msg[2:]
+			if i := strings.IndexAny(name, " \t:=\""); i >= 0 {
+				name = name[:i]
+			}
+			flagErr.Section = lookupSection(fss, name)
+			if rest, ok := strings.CutPrefix(msg, "--"+name+": "); ok && len(flagErr.Section) > 0 {
+				flagErr.Flag = name
+				flagErr.Err = errors.New(rest)
+			}
+		}
+		if len(flagErr.Flag) > 0 && len(flagErr.Section) == 0 {
+			flagErr.Section = lookupSection(fss, flagErr.Flag)
+		}
+		labelled = append(labelled, flagErr)
+	}
+	return errors.Join(labelled...)
+}
+
+// lookupSection returns the first section of fss containing the flag,
+// or an empty string if there is none.
+func lookupSection(fss cliflag.NamedFlagSets, name string) string {
+	for _, section := range fss.Order {
+		if fss.FlagSets[section].Lookup(name) != nil {
+			return section
+		}
+	}
+	return ""
+}
+
+// Runner parses the flags of options, completes and validates them, then runs a function.
+type Runner struct {
+	// Name is the name of the component, it is used in the usage messages.
+	Name string
+	// ErrOut is the destination of the errors, os.Stderr is used if it is nil.
+	ErrOut io.Writer
+}
+
+// NewRunner creates a Runner of the component with the given name.
+func NewRunner(name string) *Runner {
+	return &Runner{Name: name}
+}
+
+// Run registers the flags of o, parses args, completes and validates o, prints the warnings
+// of o if it implements Warner, then calls run with the arguments which are not flags.
+// All validation errors are printed at once, one line each labelled with its section and
+// flag name, e.g.
+//
+//	Error: invalid options:
+//	  [secure serving] --tls-min-version: unknown tls version "VersionTLS14"
+//	  [secure serving] --tls-cert-file and --tls-private-key-file must be specified together
+//
+// The error is returned as well, run is not called then.
+func (r *Runner) Run(o Options, args []string, run func(args []string) error) error {
+	errOut := r.ErrOut
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+
+	fss := o.Flags()
+	fs := pflag.NewFlagSet(r.Name, pflag.ContinueOnError)
+	fs.SetOutput(errOut)
+	for _, name := range fss.Order {
+		fs.AddFlagSet(fss.FlagSets[name])
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := CompleteAndValidate(o, fss); err != nil {
+		PrintErrors(errOut, err)
+		return err
+	}
+	PrintWarnings(errOut, o)
+	return run(fs.Args())
+}
+
+// PrintErrors prints the errors joined by CompleteAndValidate, one line each.
+func PrintErrors(w io.Writer, err error) {
+	_, _ = fmt.Fprintln(w, "Error: invalid options:")
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		_, _ = fmt.Fprintf(w, "  %v\n", e)
+	}
+}
+
+// PrintWarnings prints the warnings of o if it implements Warner, one line each, e.g.
+//
+//	Warning: --client-insecure-skip-tls-verify is set, the server certificates are NOT verified ...
+func PrintWarnings(w io.Writer, o interface{}) {
+	for _, warning := range WarningsAll(o) {
+		_, _ = fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,163 @@
+package options
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+type logOptions struct {
+	Level string
+}
+
+func (o *logOptions) Validate() []error {
+	if o.Level != "info" && o.Level != "debug" {
+		return []error{&FlagError{Flag: "log-level", Err: fmt.Errorf("unknown level %q", o.Level)}}
+	}
+	return nil
+}
+
+type testOptions struct {
+	SecureServing *SecureServingOptions
+	Logs          *logOptions
+	Name          string
+}
+
+func (o *testOptions) Flags() (fss cliflag.NamedFlagSets) {
+	o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
+	fss.FlagSet("logs").StringVar(&o.Logs.Level, "log-level", o.Logs.Level, "")
+	fss.FlagSet("generic").StringVar(&o.Name, "name", o.Name, "")
+	return fss
+}
+
+func (o *testOptions) Complete() error {
+	if len(o.Name) == 0 {
+		return errors.New("--name: must not be empty")
+	}
+	return CompleteAll(o.SecureServing, o.Logs)
+}
+
+func (o *testOptions) Validate() []error {
+	return ValidateAll(o.SecureServing, o.Logs)
+}
+
+func newTestOptions() *testOptions {
+	return &testOptions{SecureServing: NewSecureServingOptions(), Logs: &logOptions{Level: "info"}, Name: "demo"}
+}
+
+func TestRunner(t *testing.T) {
+	cases := []struct {
+		desc   string
+		args   []string
+		expect string
+		ran    []string
+	}{
+		{
+			desc: "valid",
+			args: []string{"--log-level=debug", "arg"},
+			ran:  []string{"arg"},
+		},
+		{
+			desc: "complete error",
+			args: []string{"--name="},
+			expect: "Error: invalid options:\n" +
+				"  [generic] --name: must not be empty\n",
+		},
+		{
+			desc: "validation errors",
+			args: []string{"--tls-cert-file=a.crt", "--tls-min-version=VersionTLS14", "--log-level=trace"},
+			expect: "Error: invalid options:\n" +
+				"  [secure serving] --tls-cert-file and --tls-private-key-file must be specified together\n" +
+				"  [secure serving] --tls-min-version: unknown tls version \"VersionTLS14\"\n" +
+				"  [logs] --log-level: unknown level \"trace\"\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var errOut bytes.Buffer
+			r := NewRunner("test")
+			r.ErrOut = &errOut
+			var ran []string
+			err := r.Run(newTestOptions(), c.args, func(args []string) error {
+				ran = args
+				return nil
+			})
+			if (err != nil) != (len(c.expect) > 0) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if errOut.String() != c.expect {
+				t.Errorf("expect output\n%s\nbut got\n%s", c.expect, errOut.String())
+			}
+			if !reflect.DeepEqual(ran, c.ran) {
+				t.Errorf("expect run with %v but got %v", c.ran, ran)
+			}
+		})
+	}
+}
+
+func TestLabelErrors(t *testing.T) {
+	o := newTestOptions()
+	fss := o.Flags()
+	err := LabelErrors(fss, []error{
+		errors.New("--unknown: not a flag"),
+		errors.New("no flag"),
+		&FlagError{Flag: "name", Err: errors.New("invalid")},
+		&FlagError{Section: "custom", Err: errors.New("invalid")},
+	})
+	expect := []string{
+		"--unknown: not a flag",
+		"no flag",
+		"[generic] --name: invalid",
+		"[custom] invalid",
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %q but got %q", expect, got)
+	}
+	if LabelErrors(fss, nil) != nil {
+		t.Fatal("expect no error")
+	}
+}
+
+type warnedOptions struct {
+	*testOptions
+	ClientTLS *ClientTLSOptions
+}
+
+func (o *warnedOptions) Flags() cliflag.NamedFlagSets {
+	fss := o.testOptions.Flags()
+	o.ClientTLS.AddFlags(fss.FlagSet("client tls"))
+	return fss
+}
+
+func (o *warnedOptions) Warnings() []string {
+	return WarningsAll(o.SecureServing, o.Logs, o.ClientTLS)
+}
+
+func TestRunnerWarnings(t *testing.T) {
+	var errOut bytes.Buffer
+	r := NewRunner("test")
+	r.ErrOut = &errOut
+	o := &warnedOptions{testOptions: newTestOptions(), ClientTLS: NewClientTLSOptions()}
+	ran := false
+	err := r.Run(o, []string{"--client-insecure-skip-tls-verify"}, func([]string) error {
+		ran = true
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("expect run without error, got %t, %v", ran, err)
+	}
+	if expect := "Warning: --client-insecure-skip-tls-verify is set"; !strings.HasPrefix(errOut.String(), expect) {
+		t.Errorf("expect the warning %q but got %q", expect, errOut.String())
+	}
+
+	errOut.Reset()
+	o = &warnedOptions{testOptions: newTestOptions(), ClientTLS: NewClientTLSOptions()}
+	if err = r.Run(o, nil, func([]string) error { return nil }); err != nil || errOut.Len() > 0 {
+		t.Errorf("expect no warning, got %v, %q", err, errOut.String())
+	}
+}
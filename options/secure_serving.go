@@ -0,0 +1,359 @@
+package options
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/shipengqi/component-base/cert"
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// SecureServingOptions contains the options for serving TLS.
+type SecureServingOptions struct {
+	// CertFile is a file containing the default x509 certificate for HTTPS.
+	CertFile string
+	// KeyFile is a file containing the default x509 private key matching CertFile.
+	KeyFile string
+	// SNICertKeys are named certificates used to serve secure traffic with SNI support.
+	SNICertKeys []cliflag.NamedCertKey
+	// CipherSuites is the list of allowed cipher suites for the server.
+	// Values are from tls package constants (https://golang.org/pkg/crypto/tls/#pkg-constants).
+	CipherSuites []string
+	// MinTLSVersion is the minimum TLS version supported.
+	// Values are from tls package constants (https://golang.org/pkg/crypto/tls/#pkg-constants).
+	MinTLSVersion string
+	// DisableHTTP2 indicates that HTTP/2 is not served, so the cipher suites
+	// don't need to include one of the suites required by HTTP/2.
+	DisableHTTP2 bool
+	// TLSProfile is the name of the TLS security profile which provides the minimum TLS version,
+	// the cipher suites and the curve preferences. CipherSuites and MinTLSVersion override the profile.
+	TLSProfile string
+	// CertDirectory is the directory where the self-signed certificates are written
+	// when CertFile and KeyFile are not specified.
+	CertDirectory string
+	// PairName is the name which will be used with CertDirectory to make a cert and key filenames.
+	// It becomes CertDirectory/PairName.crt and CertDirectory/PairName.key
+	PairName string
+	// KeyType is the type of the keys of the self-signed certificates. Defaults to cert.KeyTypeECDSA.
+	KeyType cert.KeyType
+	// Validity is the validity period of the self-signed certificates. Defaults to cert.DefaultValidity.
+	Validity time.Duration
+}
+
+// NewSecureServingOptions creates a SecureServingOptions with default parameters.
+func NewSecureServingOptions() *SecureServingOptions {
+	return &SecureServingOptions{
+		PairName: cert.DefaultPairName,
+	}
+}
+
+// AddFlags adds flags related to TLS serving to the specified FlagSet.
+func (o *SecureServingOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+
+	fs.StringVar(&o.CertFile, "tls-cert-file", o.CertFile, ""+
+		"File containing the default x509 Certificate for HTTPS. (CA cert, if any, concatenated "+
+		"after server cert).")
+
+	fs.StringVar(&o.KeyFile, "tls-private-key-file", o.KeyFile,
+		"File containing the default x509 private key matching --tls-cert-file.")
+
+	fs.StringVar(&o.CertDirectory, "cert-dir", o.CertDirectory, ""+
+		"The directory where the self-signed TLS certs are located. "+
+		"If --tls-cert-file and --tls-private-key-file are provided, this flag will be ignored.")
+
+	tlsCipherPreferredValues := cliflag.PreferredTLSCipherNames()
+	tlsCipherInsecureValues := cliflag.InsecureTLSCipherNames()
+	fs.Var(cliflag.NewTLSCipherSuitesValue(&o.CipherSuites), "tls-cipher-suites",
+		"Comma-separated list of cipher suites for the server. "+
+			"If omitted, the default Go cipher suites will be used. "+
+			"Use \"help\" to print the details of all cipher suites and exit. \n"+
+			"Preferred values: "+strings.Join(tlsCipherPreferredValues, ", ")+". \n"+
+			"Insecure values: "+strings.Join(tlsCipherInsecureValues, ", ")+".")
+
+	tlsPossibleVersions := cliflag.TLSPossibleVersions()
+	fs.StringVar(&o.MinTLSVersion, "tls-min-version", o.MinTLSVersion,
+		"Minimum TLS version supported. "+
+			"Possible values: "+strings.Join(tlsPossibleVersions, ", "))
+
+	profiles := make([]string, 0, len(cliflag.TLSProfileNames()))
+	for _, name := range cliflag.TLSProfileNames() {
+		profile, _ := cliflag.TLSProfile(name)
+		profiles = append(profiles, profile.String())
+	}
+	fs.StringVar(&o.TLSProfile, "tls-profile", o.TLSProfile,
+		"TLS security profile, based on the Mozilla server side TLS guidelines. "+
+			"--tls-cipher-suites and --tls-min-version override the profile. "+
+			"If omitted, the default Go settings will be used. Possible values: \n"+
+			strings.Join(profiles, ". \n")+".")
+
+	fs.Var(cliflag.NewNamedCertKeyArray(&o.SNICertKeys), "tls-sni-cert-key", ""+
+		"A pair of x509 certificate and private key file paths, optionally suffixed with a list of "+
+		"domain patterns which are fully qualified domain names, possibly with prefixed wildcard "+
+		"segments. The domain patterns also allow IP addresses. If no domain patterns are provided, "+
+		"the DNS and IP SANs of the certificate are used, or its common name if it has no SANs. "+
+		"Non-wildcard matches trump over wildcard matches, explicit domain patterns trump over "+
+		"extracted names, and earlier pairs trump over later ones. "+
+		"For multiple key/certificate pairs, use the --tls-sni-cert-key multiple times. "+
+		"Examples: \"example.crt,example.key\" or \"foo.crt,foo.key:*.foo.com,foo.com\".")
+}
+
+// Validate checks validation of SecureServingOptions.
+func (o *SecureServingOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+
+	var errs []error
+
+	if (len(o.CertFile) == 0) != (len(o.KeyFile) == 0) {
+		errs = append(errs, fmt.Errorf("--tls-cert-file and --tls-private-key-file must be specified together"))
+	}
+
+	sniPathsValid := true
+	for _, nck := range o.SNICertKeys {
+		if len(nck.CertFile) == 0 || len(nck.KeyFile) == 0 {
+			errs = append(errs, fmt.Errorf("--tls-sni-cert-key %q: both certificate and key file paths are required", nck.String()))
+			sniPathsValid = false
+		}
+	}
+	if sniPathsValid && len(o.SNICertKeys) > 0 {
+		_, sniErrs := cert.ResolveSNINames(o.SNICertKeys)
+		for _, err := range sniErrs {
+			errs = append(errs, fmt.Errorf("--tls-sni-cert-key: %v", err))
+		}
+	}
+
+	tlsSettingsValid := true
+	if _, err := cliflag.TLSCipherSuites(o.CipherSuites); err != nil {
+		errs = append(errs, fmt.Errorf("--tls-cipher-suites: %v", err))
+		tlsSettingsValid = false
+	}
+
+	if _, err := cliflag.TLSVersion(o.MinTLSVersion); err != nil {
+		errs = append(errs, fmt.Errorf("--tls-min-version: %v", err))
+		tlsSettingsValid = false
+	}
+
+	if len(o.TLSProfile) > 0 {
+		if _, err := cliflag.TLSProfile(o.TLSProfile); err != nil {
+			errs = append(errs, fmt.Errorf("--tls-profile: %v", err))
+			tlsSettingsValid = false
+		}
+	}
+
+	if tlsSettingsValid {
+		_, cipherErrs := o.validateCipherSuites()
+		errs = append(errs, cipherErrs...)
+
+		_, minVersionName, _, _ := o.tlsSettings()
+		minVersion, _ := cliflag.TLSVersion(minVersionName)
+		if _, err := fipsMaxVersion(minVersion); err != nil {
+			flag := "--tls-min-version"
+			if len(o.MinTLSVersion) == 0 {
+				flag = "--tls-profile"
+			}
+			errs = append(errs, fmt.Errorf("%s: %v", flag, err))
+		}
+	}
+
+	return errs
+}
+
+// Warnings returns the warnings about the TLS settings which are valid, but
+// don't work as they may be expected to, e.g. cipher suites which Go ignores.
+func (o *SecureServingOptions) Warnings() []string {
+	if o == nil {
+		return nil
+	}
+	warnings, _ := o.validateCipherSuites()
+	return warnings
+}
+
+// validateCipherSuites checks the effective cipher suites against the effective min TLS version,
+// both of the profile unless they are overridden, e.g. a --tls-min-version which is lower than
+// the one of the profile may have no usable cipher suite of the profile. The problems are
+// reported for the explicit flag which causes them.
+func (o *SecureServingOptions) validateCipherSuites() ([]string, []error) {
+	cipherNames, minVersionName, _, err := o.tlsSettings()
+	if err != nil || len(cipherNames) == 0 {
+		return nil, nil
+	}
+	if len(o.CipherSuites) > 0 {
+		warnings, errs := cliflag.ValidateTLSCipherSuites(minVersionName, "", cipherNames, !o.DisableHTTP2)
+		for i, err := range errs {
+			errs[i] = fmt.Errorf("--tls-cipher-suites: %v", err)
+		}
+		return warnings, errs
+	}
+
+	flag := "--tls-profile"
+	if len(o.MinTLSVersion) > 0 {
+		flag = "--tls-min-version"
+	}
+	warnings, errs := cliflag.ValidateTLSProfileCipherSuites(minVersionName, "", cipherNames, !o.DisableHTTP2)
+	for i, w := range warnings {
+		warnings[i] = fmt.Sprintf("the cipher suites of the tls profile %q: %s", o.TLSProfile, w)
+	}
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("%s: the cipher suites of the tls profile %q: %v", flag, o.TLSProfile, err)
+	}
+	return warnings, errs
+}
+
+// MaybeDefaultWithSelfSignedCerts generates a self-signed CA and a serving certificate
+// for the given hostnames and IPs in CertDirectory if CertFile and KeyFile are not
+// specified, and uses them as the default certificate. The certificates of previous
+// runs are reused if they are still valid and cover the hostnames and IPs.
+func (o *SecureServingOptions) MaybeDefaultWithSelfSignedCerts(hostnames []string, ips []net.IP) error {
+	if o == nil || len(o.CertFile) > 0 || len(o.KeyFile) > 0 {
+		return nil
+	}
+	if len(o.CertDirectory) == 0 {
+		return fmt.Errorf("--cert-dir is required to generate self-signed certificates")
+	}
+
+	nck, err := cert.GenerateSelfSignedCertKey(cert.SelfSignedConfig{
+		Dir:       o.CertDirectory,
+		PairName:  o.PairName,
+		Hostnames: hostnames,
+		IPs:       ips,
+		KeyType:   o.KeyType,
+		Validity:  o.Validity,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to generate self signed cert: %v", err)
+	}
+	o.CertFile, o.KeyFile = nck.CertFile, nck.KeyFile
+	return nil
+}
+
+// TLSConfig builds a *tls.Config from the options. The returned config
+// serves the default certificate unless the requested server name matches
+// one of the SNI certificates. The certificates are loaded once, use
+// DynamicTLSConfig to pick up rotated certificates. In FIPS mode, the TLS
+// version is limited by cliflag.TLSFIPSMaxVersion.
+func (o *SecureServingOptions) TLSConfig() (*tls.Config, error) {
+	config, _, err := o.tlsConfig()
+	return config, err
+}
+
+// DynamicTLSConfig is like TLSConfig, but the certificate and key files are
+// polled with the given interval and reloaded when they change, until the
+// context is done. Listeners are told about each reload.
+func (o *SecureServingOptions) DynamicTLSConfig(ctx context.Context, interval time.Duration, listeners ...cert.Listener) (*tls.Config, error) {
+	config, certs, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if certs != nil {
+		for _, listener := range listeners {
+			certs.AddListener(listener)
+		}
+		go certs.Run(ctx, interval)
+	}
+	return config, nil
+}
+
+// tlsSettings returns the cipher suite names, the min TLS version name and the curve names,
+// the explicit cipher suites and min TLS version override the ones of the profile.
+func (o *SecureServingOptions) tlsSettings() ([]string, string, []string, error) {
+	cipherNames, minVersionName := o.CipherSuites, o.MinTLSVersion
+	var curveNames []string
+	if len(o.TLSProfile) > 0 {
+		profile, err := cliflag.TLSProfile(o.TLSProfile)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if len(cipherNames) == 0 {
+			cipherNames = profile.CipherSuites
+		}
+		if len(minVersionName) == 0 {
+			minVersionName = profile.MinVersion
+		}
+		curveNames = profile.CurvePreferences
+	}
+	return cipherNames, minVersionName, curveNames, nil
+}
+
+func (o *SecureServingOptions) tlsConfig() (*tls.Config, *cert.DynamicServingCertificates, error) {
+	cipherNames, minVersionName, curveNames, err := o.tlsSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	cipherNames, curveNames = fipsDefaults(cipherNames, curveNames)
+
+	cipherSuites, err := cliflag.TLSCipherSuites(cipherNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	minVersion, err := cliflag.TLSVersion(minVersionName)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxVersion, err := fipsMaxVersion(minVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	curvePreferences, err := cliflag.TLSCurves(curveNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// #nosec G402 -- the minimum version defaults to TLS 1.2, older versions must be chosen explicitly
+	config := &tls.Config{
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curvePreferences,
+	}
+
+	var defaultCertKey *cliflag.NamedCertKey
+	if len(o.CertFile) > 0 && len(o.KeyFile) > 0 {
+		defaultCertKey = &cliflag.NamedCertKey{CertFile: o.CertFile, KeyFile: o.KeyFile}
+	}
+	if defaultCertKey == nil && len(o.SNICertKeys) == 0 {
+		return config, nil, nil
+	}
+
+	certs, err := cert.NewDynamicServingCertificates(defaultCertKey, o.SNICertKeys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load serving certificates: %v", err)
+	}
+	config.GetCertificate = certs.GetCertificate
+
+	return config, certs, nil
+}
+
+// fipsDefaults returns the FIPS 140 approved cipher suites and curves instead of the empty ones
+// in FIPS mode, since the Go defaults are not restricted to the approved values.
+func fipsDefaults(cipherNames, curveNames []string) ([]string, []string) {
+	if !cliflag.TLSFIPSMode() {
+		return cipherNames, curveNames
+	}
+	if len(cipherNames) == 0 {
+		cipherNames = cliflag.TLSCipherPossibleValues()
+	}
+	if len(curveNames) == 0 {
+		curveNames = cliflag.TLSPossibleCurves()
+	}
+	return cipherNames, curveNames
+}
+
+// fipsMaxVersion returns the maximum TLS version in FIPS mode, or zero if it is not limited.
+// It is an error if the minimum version is higher, e.g. TLS 1.3 without the FIPS mode of the Go runtime.
+func fipsMaxVersion(minVersion uint16) (uint16, error) {
+	maxVersion := cliflag.TLSFIPSMaxVersion()
+	if maxVersion > 0 && minVersion > maxVersion {
+		return 0, fmt.Errorf("TLS 1.3 is not allowed in FIPS mode unless the Go runtime runs in FIPS mode")
+	}
+	return maxVersion, nil
+}
@@ -0,0 +1,340 @@
+package options
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/shipengqi/component-base/cert"
+	cliflag "github.com/shipengqi/component-base/cli/flag"
+)
+
+// writeTestCertKey writes a self-signed certificate and its key for the given
+// common name and DNS names to dir, it returns the certificate and key file paths.
+func writeTestCertKey(t *testing.T, dir, cn string, dnsNames ...string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, cn+".crt")
+	keyFile := filepath.Join(dir, cn+".key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestSecureServingOptionsValidate(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errors int
+	}{
+		{
+			args: []string{},
+		},
+		{
+			args: []string{"--tls-cert-file=foo.crt", "--tls-private-key-file=foo.key"},
+		},
+		{
+			args:   []string{"--tls-cert-file=foo.crt"},
+			errors: 1,
+		},
+		{
+			args:   []string{"--tls-cipher-suites=foo", "--tls-min-version=VersionTLS99"},
+			errors: 2,
+		},
+		{
+			args: []string{"--tls-cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "--tls-min-version=VersionTLS12"},
+		},
+	}
+
+	for i, test := range tests {
+		o := NewSecureServingOptions()
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		o.AddFlags(fs)
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("%d: unexpected parse error: %v", i, err)
+		}
+		if errs := o.Validate(); len(errs) != test.errors {
+			t.Errorf("%d: expected %d errors, got %v", i, test.errors, errs)
+		}
+	}
+}
+
+func TestSecureServingOptionsTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	defaultCert, defaultKey := writeTestCertKey(t, dir, "default")
+	fooCert, fooKey := writeTestCertKey(t, dir, "foo", "foo.com")
+	wildcardCert, wildcardKey := writeTestCertKey(t, dir, "wildcard", "*.foo.com")
+
+	o := NewSecureServingOptions()
+	o.CertFile, o.KeyFile = defaultCert, defaultKey
+	o.MinTLSVersion = "VersionTLS12"
+	o.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+	o.SNICertKeys = []cliflag.NamedCertKey{
+		{CertFile: fooCert, KeyFile: fooKey, Names: []string{"foo.com"}},
+		{CertFile: wildcardCert, KeyFile: wildcardKey, Names: []string{"*.foo.com"}},
+	}
+
+	config, err := o.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected min version %d, got %d", tls.VersionTLS12, config.MinVersion)
+	}
+	if len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites %v", config.CipherSuites)
+	}
+
+	tests := []struct {
+		serverName string
+		expectedCN string
+	}{
+		{serverName: "foo.com", expectedCN: "foo"},
+		{serverName: "FOO.com", expectedCN: "foo"},
+		{serverName: "bar.foo.com", expectedCN: "wildcard"},
+		{serverName: "baz.bar.foo.com", expectedCN: "default"},
+		{serverName: "other.com", expectedCN: "default"},
+	}
+	for _, test := range tests {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.serverName, err)
+		}
+		if cert == nil {
+			t.Fatalf("%s: expected certificate %q, got nil", test.serverName, test.expectedCN)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf.Subject.CommonName != test.expectedCN {
+			t.Errorf("%s: expected certificate %q, got %q", test.serverName, test.expectedCN, leaf.Subject.CommonName)
+		}
+	}
+}
+
+func TestSecureServingOptionsMaybeDefaultWithSelfSignedCerts(t *testing.T) {
+	o := NewSecureServingOptions()
+	if err := o.MaybeDefaultWithSelfSignedCerts([]string{"localhost"}, nil); err == nil {
+		t.Errorf("expected an error without --cert-dir")
+	}
+
+	o.CertDirectory = t.TempDir()
+	if err := o.MaybeDefaultWithSelfSignedCerts([]string{"localhost"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if o.CertFile != filepath.Join(o.CertDirectory, "serving.crt") || o.KeyFile != filepath.Join(o.CertDirectory, "serving.key") {
+		t.Errorf("unexpected cert and key files %q, %q", o.CertFile, o.KeyFile)
+	}
+	if _, err := o.TLSConfig(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// explicit files are kept
+	o = NewSecureServingOptions()
+	o.CertFile, o.KeyFile = "foo.crt", "foo.key"
+	if err := o.MaybeDefaultWithSelfSignedCerts([]string{"localhost"}, nil); err != nil || o.CertFile != "foo.crt" {
+		t.Errorf("expected the explicit files to be kept, got %q, %v", o.CertFile, err)
+	}
+}
+
+func TestSecureServingOptionsTLSProfile(t *testing.T) {
+	tests := []struct {
+		args               []string
+		expectedMinVersion uint16
+		expectedCiphers    int
+		expectedCurves     []tls.CurveID
+	}{
+		{
+			args:               []string{"--tls-profile=modern"},
+			expectedMinVersion: tls.VersionTLS13,
+			expectedCiphers:    3,
+			expectedCurves:     []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		},
+		{
+			args:               []string{"--tls-profile=intermediate", "--tls-min-version=VersionTLS13"},
+			expectedMinVersion: tls.VersionTLS13,
+			expectedCiphers:    9,
+			expectedCurves:     []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		},
+		{
+			args:               []string{"--tls-profile=old", "--tls-cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			expectedMinVersion: tls.VersionTLS10,
+			expectedCiphers:    1,
+			expectedCurves:     []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		},
+	}
+	for i, test := range tests {
+		o := NewSecureServingOptions()
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		o.AddFlags(fs)
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("%d: unexpected parse error: %v", i, err)
+		}
+		if errs := o.Validate(); len(errs) != 0 {
+			t.Fatalf("%d: unexpected errors: %v", i, errs)
+		}
+		config, err := o.TLSConfig()
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if config.MinVersion != test.expectedMinVersion {
+			t.Errorf("%d: expected min version %d, got %d", i, test.expectedMinVersion, config.MinVersion)
+		}
+		if len(config.CipherSuites) != test.expectedCiphers {
+			t.Errorf("%d: expected %d cipher suites, got %d", i, test.expectedCiphers, len(config.CipherSuites))
+		}
+		if !reflect.DeepEqual(config.CurvePreferences, test.expectedCurves) {
+			t.Errorf("%d: expected curves %v, got %v", i, test.expectedCurves, config.CurvePreferences)
+		}
+	}
+
+	o := NewSecureServingOptions()
+	o.TLSProfile = "foo"
+	if errs := o.Validate(); len(errs) != 1 {
+		t.Errorf("expected one error for an unknown profile, got %v", errs)
+	}
+}
+
+func TestSecureServingOptionsCipherSuitesCombination(t *testing.T) {
+	o := NewSecureServingOptions()
+	o.MinTLSVersion = "VersionTLS13"
+	o.CipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if warnings := o.Warnings(); len(warnings) != 1 {
+		t.Errorf("expected one warning, got %v", warnings)
+	}
+
+	o = NewSecureServingOptions()
+	o.CipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}
+	if errs := o.Validate(); len(errs) != 1 {
+		t.Errorf("expected an HTTP/2 error, got %v", errs)
+	}
+	o.DisableHTTP2 = true
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestSecureServingOptionsProfileCipherSuites(t *testing.T) {
+	tests := []struct {
+		profile       string
+		minVersion    string
+		expectedErrs  int
+		expectedWarns int
+	}{
+		{profile: "modern"},
+		{profile: "intermediate"},
+		{profile: "old"},
+		{profile: "intermediate", minVersion: "VersionTLS13"},
+		// the TLS 1.3 cipher suites of the profile can't be used by TLS 1.2
+		{profile: "modern", minVersion: "VersionTLS12", expectedErrs: 1, expectedWarns: 1},
+	}
+	for _, test := range tests {
+		o := NewSecureServingOptions()
+		o.TLSProfile, o.MinTLSVersion = test.profile, test.minVersion
+		errs := o.Validate()
+		if len(errs) != test.expectedErrs {
+			t.Errorf("%s %s: expected %d errors, got %v", test.profile, test.minVersion, test.expectedErrs, errs)
+		}
+		for _, err := range errs {
+			if !strings.HasPrefix(err.Error(), "--tls-min-version: ") {
+				t.Errorf("%s %s: expected the error of --tls-min-version, got %v", test.profile, test.minVersion, err)
+			}
+		}
+		if warnings := o.Warnings(); len(warnings) != test.expectedWarns {
+			t.Errorf("%s %s: expected %d warnings, got %v", test.profile, test.minVersion, test.expectedWarns, warnings)
+		}
+	}
+}
+
+func TestSecureServingOptionsSelfSignedCertsConfig(t *testing.T) {
+	o := NewSecureServingOptions()
+	o.CertDirectory = t.TempDir()
+	o.KeyType = cert.KeyTypeRSA
+	o.Validity = 2 * time.Hour
+	if err := o.MaybeDefaultWithSelfSignedCerts([]string{"localhost"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pair.PrivateKey.(*rsa.PrivateKey); !ok {
+		t.Errorf("expected a rsa key, got %T", pair.PrivateKey)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.NotAfter.After(time.Now().Add(2 * time.Hour)) {
+		t.Errorf("expected the certificate to expire within two hours, got %s", leaf.NotAfter)
+	}
+}
+
+func TestSecureServingOptionsFIPSMaxVersion(t *testing.T) {
+	cliflag.SetTLSFIPSMode(true)
+	defer cliflag.SetTLSFIPSMode(false)
+	if cliflag.TLSFIPSMaxVersion() == 0 {
+		t.Skip("the Go runtime runs in FIPS mode")
+	}
+
+	config, err := NewSecureServingOptions().TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("expected the maximum version TLS 1.2, got %x", config.MaxVersion)
+	}
+
+	o := NewSecureServingOptions()
+	o.TLSProfile = cliflag.TLSProfileModern
+	errs := o.Validate()
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "--tls-profile: TLS 1.3 is not allowed in FIPS mode") {
+		t.Errorf("expected an error of the TLS 1.3 profile, got %v", errs)
+	}
+	if _, err = o.TLSConfig(); err == nil {
+		t.Errorf("expected no config with TLS 1.3 in FIPS mode")
+	}
+}
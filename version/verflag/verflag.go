@@ -4,6 +4,7 @@ package verflag
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
@@ -82,12 +83,21 @@ func AddFlags(fs *flag.FlagSet) {
 // PrintAndExitIfRequested will check if the -version flag was passed
 // and, if so, print the version and exit.
 func PrintAndExitIfRequested() {
+	if PrintIfRequested(os.Stdout) {
+		os.Exit(0)
+	}
+}
+
+// PrintIfRequested will check if the -version flag was passed and, if so,
+// print the version to w. It returns true if the version is printed.
+func PrintIfRequested(w io.Writer) bool {
 	switch vf := *versionFlag; vf {
 	case VersionRaw:
-		fmt.Printf("%#v\n", version.Get())
-		os.Exit(0)
+		_, _ = fmt.Fprintf(w, "%#v\n", version.Get())
+		return true
 	case VersionTrue:
-		fmt.Printf("%s\n", version.Get())
-		os.Exit(0)
+		_, _ = fmt.Fprintf(w, "%s\n", version.Get())
+		return true
 	}
+	return false
 }